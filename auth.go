@@ -0,0 +1,141 @@
+package ian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// SourceAuthTypes are the values of CalendarSource.Auth.
+var SourceAuthTypes = []string{"basic", "bearer", "header"}
+
+// ValidateAuth checks that the source's authentication is complete, and that its secret is not in the configuration.
+func (source *CalendarSource) ValidateAuth() error {
+	if source.Auth == "" {
+		if source.PasswordCommand != "" || source.PasswordEnv != "" {
+			return errors.New("a password is set, but no auth")
+		}
+		return nil
+	}
+
+	switch source.Auth {
+	case "basic":
+		if source.Username == "" {
+			return errors.New("basic auth requires a username")
+		}
+	case "bearer":
+	case "header":
+		if source.Header == "" {
+			return errors.New("header auth requires a header")
+		}
+	default:
+		return fmt.Errorf("unknown auth '%s' (must be one of %s)", source.Auth, strings.Join(SourceAuthTypes, ", "))
+	}
+
+	if (source.PasswordCommand == "") == (source.PasswordEnv == "") {
+		return errors.New("auth requires either a passwordcommand or a passwordenv")
+	}
+	return nil
+}
+
+// passwords are the outputs of the password commands that have been run, so that each command is only run once.
+var passwords = map[string]string{}
+
+// passwordsMutex guards passwords, and makes the password commands run one at a time, so their prompts do not mix.
+var passwordsMutex sync.Mutex
+
+// password returns the secret of the source, from its password command (the first line of its output) or its environment variable.
+// The output of the command is kept for the rest of the invocation.
+func (source *CalendarSource) password(ctx context.Context) (string, error) {
+	if source.PasswordEnv != "" {
+		password, ok := os.LookupEnv(source.PasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("the environment variable '%s' is not set", source.PasswordEnv)
+		}
+		return password, nil
+	}
+
+	passwordsMutex.Lock()
+	defer passwordsMutex.Unlock()
+	if password, ok := passwords[source.PasswordCommand]; ok {
+		return password, nil
+	}
+
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.CommandContext(ctx, source.PasswordCommand)
+	default:
+		cmd = exec.CommandContext(ctx, "sh", "-c", source.PasswordCommand)
+	}
+
+	// The command may prompt for a passphrase.
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("password command failed: %s", err)
+	}
+	password, _, _ := strings.Cut(string(out), "\n")
+	password = strings.TrimSuffix(password, "\r")
+	passwords[source.PasswordCommand] = password
+	return password, nil
+}
+
+// httpClient returns the client for the source's requests, which authenticates them if the source has auth.
+func (source *CalendarSource) httpClient(ctx context.Context) (*http.Client, error) {
+	if source.Auth == "" {
+		return http.DefaultClient, nil
+	}
+
+	u, err := url.Parse(source.calDavEndpoint())
+	if err != nil {
+		return nil, err
+	}
+	password, err := source.password(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &authTransport{
+			source:   source,
+			host:     u.Host,
+			password: password,
+		},
+	}, nil
+}
+
+// authTransport authenticates the requests to the source's host.
+// Requests to other hosts (e.g. after a redirect) are not authenticated, so the secret is not leaked.
+type authTransport struct {
+	source   *CalendarSource
+	host     string
+	password string
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	switch t.source.Auth {
+	case "basic":
+		req.SetBasicAuth(t.source.Username, t.password)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+t.password)
+	case "header":
+		req.Header.Set(t.source.Header, t.password)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
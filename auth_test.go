@@ -0,0 +1,113 @@
+package ian
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSourceAuth(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T090000Z\r\nDTEND:20240506T100000Z\r\nSUMMARY:lecture\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		switch {
+		case r.URL.Path == "/basic" && ok && user == "mary" && password == "hunter2":
+		case r.URL.Path == "/bearer" && r.Header.Get("Authorization") == "Bearer t0ken":
+		case r.URL.Path == "/header" && r.Header.Get("X-API-Key") == "k3y":
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	t.Setenv("IAN_TEST_PASSWORD", "hunter2")
+
+	for _, test := range []struct {
+		source CalendarSource
+		ok     bool
+	}{
+		{CalendarSource{Source: server.URL + "/basic", Auth: "basic", Username: "mary", PasswordEnv: "IAN_TEST_PASSWORD"}, true},
+		{CalendarSource{Source: server.URL + "/bearer", Auth: "bearer", PasswordCommand: "printf 't0ken\\nsecond line'"}, true},
+		{CalendarSource{Source: server.URL + "/header", Auth: "header", Header: "X-API-Key", PasswordCommand: "echo k3y"}, true},
+		{CalendarSource{Source: server.URL + "/basic", Auth: "basic", Username: "mary", PasswordCommand: "echo wrong"}, false},
+		{CalendarSource{Source: server.URL + "/bearer", Auth: "bearer", PasswordCommand: "exit 1"}, false},
+		{CalendarSource{Source: server.URL + "/bearer", Auth: "bearer", PasswordEnv: "IAN_TEST_UNSET"}, false},
+	} {
+		test.source.Type = "ical"
+		if err := test.source.ValidateAuth(); err != nil {
+			t.Fatal(err)
+		}
+		_, err := test.source.importIcal(context.Background(), "school", &CacheJournalSource{})
+		if test.ok && err != nil {
+			t.Errorf("%s auth: %s", test.source.Auth, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%s auth: expected an error", test.source.Auth)
+		}
+	}
+
+	for _, source := range []CalendarSource{
+		{Auth: "basic", PasswordEnv: "IAN_TEST_PASSWORD"},
+		{Auth: "header", PasswordEnv: "IAN_TEST_PASSWORD"},
+		{Auth: "bearer"},
+		{Auth: "bearer", PasswordEnv: "IAN_TEST_PASSWORD", PasswordCommand: "echo"},
+		{Auth: "digest", PasswordEnv: "IAN_TEST_PASSWORD"},
+		{PasswordEnv: "IAN_TEST_PASSWORD"},
+	} {
+		if err := source.ValidateAuth(); err == nil {
+			t.Errorf("expected %+v to be invalid", source)
+		}
+	}
+}
+
+func TestSourcePasswordBeforeUpdates(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T090000Z\r\nDTEND:20240506T100000Z\r\nSUMMARY:lecture\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	runs := filepath.Join(root, ".runs")
+	// Slower than the timeout, like a command that prompts.
+	command := fmt.Sprintf("echo run >> '%s'; sleep 0.2; echo t0ken", runs)
+
+	instance := &Instance{Root: root}
+	instance.Config.Sources = map[string]CalendarSource{
+		"school": {Source: server.URL, Type: "ical", Auth: "bearer", PasswordCommand: command, Timeout_: 100 * time.Millisecond, Lifetime_: time.Nanosecond},
+	}
+
+	for range 2 {
+		if err := instance.UpdateSources(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		journal, err := instance.ReadCacheJournal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := journal.Sources["school"].Error; err != "" {
+			t.Fatalf("expected the password command not to count towards the timeout, got %s", err)
+		}
+	}
+
+	buf, err := os.ReadFile(runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(buf), "run"); n != 1 {
+		t.Errorf("expected the password command to run once, got %d", n)
+	}
+}
@@ -0,0 +1,410 @@
+package ian
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav/caldav"
+)
+
+// CalDavJournalFileName is the file in a CalDAV source's cache directory that keeps track of the cached remote objects.
+const CalDavJournalFileName string = ".caldav-journal.toml"
+
+// CalDavJournal keeps track of the remote objects that the cached events and to-dos of a CalDAV source come from.
+type CalDavJournal struct {
+	// Calendar is the path of the calendar collection on the server, where new objects are created.
+	Calendar string
+	// Objects are keyed by the UID of their components.
+	Objects map[string]CalDavObject
+}
+
+type CalDavObject struct {
+	Path string
+	// ETag is the entity tag of the object when it was cached. Changes are only written if it still matches.
+	ETag string
+}
+
+// calDavEndpoint returns the HTTP URL of the source.
+func (source *CalendarSource) calDavEndpoint() string {
+	// WebCal URLs are plain HTTPS.
+	if rest, ok := strings.CutPrefix(source.Source, "webcal://"); ok {
+		return "https://" + rest
+	}
+	return source.Source
+}
+
+// calDavObjectUrl returns the URL of the object path on the source's server.
+func (source *CalendarSource) calDavObjectUrl(objectPath string) (string, error) {
+	u, err := url.Parse(source.calDavEndpoint())
+	if err != nil {
+		return "", err
+	}
+	return u.ResolveReference(&url.URL{Path: objectPath}).String(), nil
+}
+
+// importCalDav fetches all objects (events and to-dos) of the source's CalDAV calendar, merged into one calendar.
+// The returned journal lists the fetched objects.
+func (source *CalendarSource) importCalDav(ctx context.Context, name string) (*ical.Calendar, *CalDavJournal, error) {
+	endpoint := source.calDavEndpoint()
+
+	httpClient, err := source.httpClient(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source '%s': %s", name, err)
+	}
+	client, err := caldav.NewClient(httpClient, endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	calendarPath, err := findCalDavCalendar(ctx, client, endpoint)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source '%s': %s", name, err)
+	}
+
+	if Verbose {
+		log.Printf("querying CalDAV calendar '%s' of source '%s'\n", calendarPath, name)
+	}
+
+	objects, err := client.QueryCalendar(ctx, calendarPath, &caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{
+			Name:     ical.CompCalendar,
+			AllProps: true,
+			AllComps: true,
+		},
+		CompFilter: caldav.CompFilter{
+			Name: ical.CompCalendar,
+		},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("source '%s' CalDAV query failed: %s", name, err)
+	}
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//ian//ian caldav source")
+
+	journal := &CalDavJournal{
+		Calendar: calendarPath,
+		Objects:  map[string]CalDavObject{},
+	}
+	timeZones := map[string]bool{}
+
+	for _, object := range objects {
+		// Some servers answer queries with objects from every calendar.
+		if object.Data == nil || !strings.HasPrefix(object.Path, calendarPath) {
+			continue
+		}
+		for _, child := range object.Data.Children {
+			switch child.Name {
+			case ical.CompTimezone:
+				// Every object carries the time zones it uses, so skip duplicates.
+				tzid, _ := child.Props.Text(ical.PropTimezoneID)
+				if timeZones[tzid] {
+					continue
+				}
+				timeZones[tzid] = true
+			case ical.CompEvent, ical.CompToDo:
+				if uid, _ := child.Props.Text(ical.PropUID); uid != "" {
+					journal.Objects[uid] = CalDavObject{
+						Path: object.Path,
+						ETag: object.ETag,
+					}
+				}
+			}
+			cal.Children = append(cal.Children, child)
+		}
+	}
+
+	return cal, journal, nil
+}
+
+// findCalDavCalendar finds the path of the calendar that source refers to.
+// source may either be the URL of the calendar itself, or of a CalDAV server with exactly one calendar.
+// If the server does not support discovery, source is assumed to be the calendar.
+func findCalDavCalendar(ctx context.Context, client *caldav.Client, source string) (string, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", err
+	}
+	sourcePath := strings.TrimSuffix(u.Path, "/")
+
+	principal, err := client.FindCurrentUserPrincipal(ctx)
+	if err != nil {
+		if Verbose {
+			log.Printf("CalDAV discovery failed, and '%s' is used as the calendar: %s\n", source, err)
+		}
+		return sourcePath + "/", nil
+	}
+	homeSet, err := client.FindCalendarHomeSet(ctx, principal)
+	if err != nil {
+		return "", fmt.Errorf("failed to find calendar home set: %s", err)
+	}
+	calendars, err := client.FindCalendars(ctx, homeSet)
+	if err != nil {
+		return "", fmt.Errorf("failed to list calendars: %s", err)
+	}
+
+	paths := []string{}
+	for _, calendar := range calendars {
+		if strings.TrimSuffix(calendar.Path, "/") == sourcePath {
+			return calendar.Path, nil
+		}
+		paths = append(paths, calendar.Path)
+	}
+
+	switch len(calendars) {
+	case 0:
+		return "", fmt.Errorf("no calendars found in '%s'", homeSet)
+	case 1:
+		return calendars[0].Path, nil
+	default:
+		return "", fmt.Errorf("the URL does not point at one calendar. use one of these paths: %s", strings.Join(paths, ", "))
+	}
+}
+
+func (instance *Instance) getCalDavJournalPath(name string) string {
+	return filepath.Join(instance.getCacheDir(), name, CalDavJournalFileName)
+}
+
+func (instance *Instance) readCalDavJournal(name string) (CalDavJournal, error) {
+	var journal CalDavJournal
+	if _, err := toml.DecodeFile(instance.getCalDavJournalPath(name), &journal); err != nil {
+		return CalDavJournal{}, fmt.Errorf("source '%s' has no valid CalDAV journal (try updating the source): %s", name, err)
+	}
+	if journal.Objects == nil {
+		journal.Objects = map[string]CalDavObject{}
+	}
+	return journal, nil
+}
+
+func writeCalDavJournal(path string, journal CalDavJournal) error {
+	buf := new(bytes.Buffer)
+	buf.WriteString("# This file is automatically generated and managed.\n\n")
+	if err := toml.NewEncoder(buf).Encode(journal); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// WriteSourceEvent writes an event to a writable source, replacing the event with the same UID and recurrence ID.
+func (instance *Instance) WriteSourceEvent(name string, props EventProperties) error {
+	return instance.updateCalDavObject(context.Background(), name, props.Uid, func(components []EventProperties) []EventProperties {
+		components = slices.DeleteFunc(components, func(c EventProperties) bool {
+			return c.RecurrenceId.Equal(props.RecurrenceId)
+		})
+		return append(components, props)
+	})
+}
+
+// DeleteSourceEvent deletes an event from a writable source.
+// Deleting a recurring event also deletes its overrides.
+func (instance *Instance) DeleteSourceEvent(name string, props EventProperties) error {
+	return instance.updateCalDavObject(context.Background(), name, props.Uid, func(components []EventProperties) []EventProperties {
+		if !props.IsOverride() {
+			return nil
+		}
+		return slices.DeleteFunc(components, func(c EventProperties) bool {
+			return c.RecurrenceId.Equal(props.RecurrenceId)
+		})
+	})
+}
+
+// updateCalDavObject changes the events of the remote object with the UID in a writable CalDAV source, and refreshes its cache.
+// change gets the cached events of the object (a recurring event and its overrides), and returns the events to write.
+// If there are none, the object is deleted.
+//
+// The object is only written if it has not been changed on the server since it was cached.
+// Otherwise, its cache is refreshed and a conflict is reported.
+func (instance *Instance) updateCalDavObject(ctx context.Context, name, uid string, change func([]EventProperties) []EventProperties) error {
+	source, ok := instance.Config.Sources[name]
+	if !ok {
+		return fmt.Errorf("no source named '%s'", name)
+	}
+	if !source.Writable {
+		return fmt.Errorf("source '%s' is not writable", name)
+	}
+
+	journal, err := instance.readCalDavJournal(name)
+	if err != nil {
+		return err
+	}
+
+	eventsProps, _, err := instance.readDir(filepath.Join(instance.getCacheDir(), name))
+	if err != nil {
+		return err
+	}
+	components := []EventProperties{}
+	for _, props := range eventsProps {
+		if props.Uid == uid {
+			components = append(components, props)
+		}
+	}
+	components = change(components)
+
+	object, exists := journal.Objects[uid]
+	if !exists {
+		object.Path = path.Join(journal.Calendar, url.PathEscape(uid)+".ics")
+	}
+	objectUrl, err := source.calDavObjectUrl(object.Path)
+	if err != nil {
+		return err
+	}
+
+	var req *http.Request
+	if len(components) == 0 {
+		if !exists {
+			return nil
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodDelete, objectUrl, nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		// The recurring event goes first, followed by its overrides.
+		slices.SortFunc(components, func(c1, c2 EventProperties) int {
+			return c1.RecurrenceId.Compare(c2.RecurrenceId)
+		})
+		events := []Event{}
+		for _, props := range components {
+			events = append(events, Event{Props: props})
+		}
+		buf, err := SerializeIcal(ToIcal(events, nil, ""))
+		if err != nil {
+			return err
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPut, objectUrl, &buf)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", ical.MIMEType)
+	}
+
+	if !exists {
+		req.Header.Set("If-None-Match", "*")
+	} else if object.ETag != "" {
+		req.Header.Set("If-Match", strconv.Quote(object.ETag))
+	}
+
+	if Verbose {
+		log.Printf("%s '%s' of source '%s'\n", req.Method, object.Path, name)
+	}
+
+	client, err := source.httpClient(ctx)
+	if err != nil {
+		return fmt.Errorf("source '%s': %s", name, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		if err := instance.refreshCalDavObject(ctx, client, name, &journal, uid, object.Path); err != nil {
+			log.Printf("warning: failed to refresh '%s' of source '%s': %s\n", object.Path, name, err)
+		}
+		return fmt.Errorf("conflict: '%s' in source '%s' has been changed on the server since it was cached, and was not overwritten. the cache has been refreshed, so review the changes and try again", object.Path, name)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("source '%s' CalDAV %s request failed: %s", name, req.Method, resp.Status)
+	}
+
+	return instance.refreshCalDavObject(ctx, client, name, &journal, uid, object.Path)
+}
+
+// refreshCalDavObject replaces the cache of the remote object with the UID by its current state on the server.
+func (instance *Instance) refreshCalDavObject(ctx context.Context, client *http.Client, name string, journal *CalDavJournal, uid, objectPath string) error {
+	source := instance.Config.Sources[name]
+	dir := filepath.Join(instance.getCacheDir(), name)
+
+	objectUrl, err := source.calDavObjectUrl(objectPath)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectUrl, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var eventsProps []EventProperties
+	var todosProps []TodoProperties
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		// The object has been deleted.
+		delete(journal.Objects, uid)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("source '%s' CalDAV GET request failed: %s", name, resp.Status)
+	default:
+		cal, err := ParseIcal(resp.Body)
+		if err != nil {
+			return err
+		}
+		if eventsProps, err = FromIcal(cal); err != nil {
+			return err
+		}
+		if todosProps, err = FromIcalTodos(cal); err != nil {
+			return err
+		}
+		etag, err := strconv.Unquote(resp.Header.Get("ETag"))
+		if err != nil {
+			// Without an ETag, the next change is written unconditionally.
+			etag = ""
+		}
+		journal.Objects[uid] = CalDavObject{
+			Path: objectPath,
+			ETag: etag,
+		}
+	}
+
+	cachedEvents, cachedTodos, err := instance.readDir(dir)
+	if err != nil {
+		return err
+	}
+	for file, props := range cachedEvents {
+		if props.Uid == uid {
+			if err := os.Remove(filepath.Join(dir, file)); err != nil {
+				return err
+			}
+		}
+	}
+	for file, props := range cachedTodos {
+		if props.Uid == uid {
+			if err := os.Remove(filepath.Join(dir, file)); err != nil {
+				return err
+			}
+		}
+	}
+	for i, props := range eventsProps {
+		if err := instance.CacheEvent(name, i, props); err != nil {
+			return err
+		}
+	}
+	for i, props := range todosProps {
+		if err := instance.CacheTodo(name, i, props); err != nil {
+			return err
+		}
+	}
+
+	return writeCalDavJournal(instance.getCalDavJournalPath(name), *journal)
+}
@@ -0,0 +1,316 @@
+package ian
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav"
+	"github.com/emersion/go-webdav/caldav"
+)
+
+// testCalDavBackend is an in-memory CalDAV backend with a single user.
+type testCalDavBackend struct {
+	calendars []caldav.Calendar
+	// objects are keyed by calendar path.
+	objects map[string][]caldav.CalendarObject
+	etag    int
+}
+
+func (b *testCalDavBackend) CurrentUserPrincipal(ctx context.Context) (string, error) {
+	return "/user/", nil
+}
+
+func (b *testCalDavBackend) CalendarHomeSetPath(ctx context.Context) (string, error) {
+	return "/user/calendars/", nil
+}
+
+func (b *testCalDavBackend) ListCalendars(ctx context.Context) ([]caldav.Calendar, error) {
+	return b.calendars, nil
+}
+
+func (b *testCalDavBackend) GetCalendar(ctx context.Context, path string) (*caldav.Calendar, error) {
+	for _, calendar := range b.calendars {
+		if calendar.Path == path {
+			return &calendar, nil
+		}
+	}
+	return nil, webdav.NewHTTPError(http.StatusNotFound, nil)
+}
+
+func (b *testCalDavBackend) GetCalendarObject(ctx context.Context, path string, req *caldav.CalendarCompRequest) (*caldav.CalendarObject, error) {
+	for _, objects := range b.objects {
+		for _, object := range objects {
+			if object.Path == path {
+				return &object, nil
+			}
+		}
+	}
+	return nil, webdav.NewHTTPError(http.StatusNotFound, nil)
+}
+
+func (b *testCalDavBackend) ListCalendarObjects(ctx context.Context, path string, req *caldav.CalendarCompRequest) ([]caldav.CalendarObject, error) {
+	return b.objects[path], nil
+}
+
+func (b *testCalDavBackend) QueryCalendarObjects(ctx context.Context, query *caldav.CalendarQuery) ([]caldav.CalendarObject, error) {
+	all := []caldav.CalendarObject{}
+	for _, objects := range b.objects {
+		all = append(all, objects...)
+	}
+	return caldav.Filter(query, all)
+}
+
+func (b *testCalDavBackend) PutCalendarObject(ctx context.Context, p string, calendar *ical.Calendar, opts *caldav.PutCalendarObjectOptions) (string, error) {
+	objects := b.objects[path.Dir(p)+"/"]
+	i := slices.IndexFunc(objects, func(o caldav.CalendarObject) bool {
+		return o.Path == p
+	})
+
+	if opts.IfNoneMatch.IsWildcard() && i != -1 {
+		return "", webdav.NewHTTPError(http.StatusPreconditionFailed, nil)
+	}
+	if opts.IfMatch.IsSet() {
+		etag, _ := opts.IfMatch.ETag()
+		if i == -1 || objects[i].ETag != etag {
+			return "", webdav.NewHTTPError(http.StatusPreconditionFailed, nil)
+		}
+	}
+
+	object := b.newObject(p, calendar.Children...)
+	if i == -1 {
+		b.objects[path.Dir(p)+"/"] = append(objects, object)
+	} else {
+		objects[i] = object
+	}
+	return p, nil
+}
+
+func (b *testCalDavBackend) DeleteCalendarObject(ctx context.Context, p string) error {
+	b.objects[path.Dir(p)+"/"] = slices.DeleteFunc(b.objects[path.Dir(p)+"/"], func(o caldav.CalendarObject) bool {
+		return o.Path == p
+	})
+	return nil
+}
+
+// newObject creates an object with a new ETag.
+func (b *testCalDavBackend) newObject(path string, comps ...*ical.Component) caldav.CalendarObject {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//ian//test")
+	cal.Children = append(cal.Children, comps...)
+
+	b.etag++
+	return caldav.CalendarObject{
+		Path:    path,
+		ModTime: time.Now(),
+		ETag:    fmt.Sprint(b.etag),
+		Data:    cal,
+	}
+}
+
+func TestImportCalDav(t *testing.T) {
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, GenerateUid())
+	event.Props.SetText(ical.PropSummary, "planning")
+	event.Props.SetDateTime(ical.PropDateTimeStamp, start)
+	event.Props.SetDateTime(ical.PropDateTimeStart, start)
+	event.Props.SetDateTime(ical.PropDateTimeEnd, start.Add(time.Hour))
+
+	todo := ical.NewComponent(ical.CompToDo)
+	todo.Props.SetText(ical.PropUID, GenerateUid())
+	todo.Props.SetText(ical.PropSummary, "write minutes")
+	todo.Props.SetDateTime(ical.PropDateTimeStamp, start)
+
+	other := ical.NewEvent()
+	other.Props.SetText(ical.PropUID, GenerateUid())
+	other.Props.SetText(ical.PropSummary, "dinner")
+	other.Props.SetDateTime(ical.PropDateTimeStamp, start)
+	other.Props.SetDateTime(ical.PropDateTimeStart, start.Add(10*time.Hour))
+	other.Props.SetDateTime(ical.PropDateTimeEnd, start.Add(12*time.Hour))
+
+	backend := &testCalDavBackend{
+		calendars: []caldav.Calendar{
+			{Path: "/user/calendars/work/", Name: "work", SupportedComponentSet: []string{ical.CompEvent, ical.CompToDo}},
+			{Path: "/user/calendars/home/", Name: "home", SupportedComponentSet: []string{ical.CompEvent}},
+		},
+	}
+	backend.objects = map[string][]caldav.CalendarObject{
+		"/user/calendars/work/": {
+			backend.newObject("/user/calendars/work/planning.ics", event.Component),
+			backend.newObject("/user/calendars/work/minutes.ics", todo),
+		},
+		"/user/calendars/home/": {
+			backend.newObject("/user/calendars/home/dinner.ics", other.Component),
+		},
+	}
+	server := httptest.NewServer(&caldav.Handler{Backend: backend})
+	defer server.Close()
+
+	instance := &Instance{Root: t.TempDir()}
+	source := CalendarSource{Source: server.URL + "/user/calendars/work/", Type: "caldav"}
+	instance.Config.Sources = map[string]CalendarSource{"work": source}
+
+	if err := source.ImportAndUse(context.Background(), instance, "work"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := instance.ReadCachedEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Props.Summary != "planning" || !events[0].Props.Start.Equal(start) {
+		t.Errorf("expected the 'planning' event to be cached, got %v", events)
+	}
+
+	todos, err := instance.ReadCachedTodos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 1 || todos[0].Props.Summary != "write minutes" {
+		t.Errorf("expected the 'write minutes' to-do to be cached, got %v", todos)
+	}
+
+	// The server has two calendars, so its root is ambiguous.
+	ambiguous := CalendarSource{Source: server.URL, Type: "caldav"}
+	if _, err := ambiguous.Import("ambiguous"); err == nil || !strings.Contains(err.Error(), "/user/calendars/home/") {
+		t.Errorf("expected an error listing the calendars, got %v", err)
+	}
+
+	backend.calendars = backend.calendars[:1]
+	delete(backend.objects, "/user/calendars/home/")
+	cal, err := ambiguous.Import("ambiguous")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cal.Children) != 2 {
+		t.Errorf("expected the only calendar's 2 objects to be imported, got %d", len(cal.Children))
+	}
+}
+
+func TestWriteCalDavSource(t *testing.T) {
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, GenerateUid())
+	event.Props.SetText(ical.PropSummary, "planning")
+	event.Props.SetDateTime(ical.PropDateTimeStamp, start)
+	event.Props.SetDateTime(ical.PropDateTimeStart, start)
+	event.Props.SetDateTime(ical.PropDateTimeEnd, start.Add(time.Hour))
+
+	backend := &testCalDavBackend{
+		calendars: []caldav.Calendar{
+			{Path: "/user/calendars/work/", Name: "work", SupportedComponentSet: []string{ical.CompEvent}},
+		},
+	}
+	backend.objects = map[string][]caldav.CalendarObject{
+		"/user/calendars/work/": {backend.newObject("/user/calendars/work/planning.ics", event.Component)},
+	}
+	server := httptest.NewServer(&caldav.Handler{Backend: backend})
+	defer server.Close()
+
+	instance := &Instance{Root: t.TempDir()}
+	source := CalendarSource{Source: server.URL + "/user/calendars/work/", Type: "caldav", Writable: true}
+	instance.Config.Sources = map[string]CalendarSource{"work": source}
+
+	if err := source.ImportAndUse(context.Background(), instance, "work"); err != nil {
+		t.Fatal(err)
+	}
+
+	remoteSummary := func(i int) string {
+		summary, _ := backend.objects["/user/calendars/work/"][i].Data.Children[0].Props.Text(ical.PropSummary)
+		return summary
+	}
+	readCached := func() []Event {
+		events, err := instance.ReadCachedEvents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return events
+	}
+
+	events := readCached()
+	if len(events) != 1 || events[0].Constant {
+		t.Fatalf("expected 1 writable event, got %v", events)
+	}
+
+	// Edit
+	edited := events[0]
+	edited.Props.Summary = "planning (moved)"
+	if err := edited.Write(instance); err != nil {
+		t.Fatal(err)
+	}
+	if summary := remoteSummary(0); summary != "planning (moved)" {
+		t.Errorf("expected the edit to be written to the server, got '%s'", summary)
+	}
+	if events := readCached(); len(events) != 1 || events[0].Props.Summary != "planning (moved)" {
+		t.Errorf("expected the cache to be refreshed, got %v", events)
+	}
+
+	// Conflicting edit
+	changed := event.Component
+	changed.Props.SetText(ical.PropSummary, "planning (cancelled)")
+	backend.objects["/user/calendars/work/"][0] = backend.newObject("/user/calendars/work/planning.ics", changed)
+
+	edited.Props.Summary = "planning (again)"
+	if err := edited.Write(instance); err == nil || !strings.Contains(err.Error(), "conflict") {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+	if summary := remoteSummary(0); summary != "planning (cancelled)" {
+		t.Errorf("expected the change on the server to be kept, got '%s'", summary)
+	}
+	if events := readCached(); len(events) != 1 || events[0].Props.Summary != "planning (cancelled)" {
+		t.Errorf("expected the cache to be refreshed after the conflict, got %v", events)
+	}
+
+	// Add
+	p, err := NewEventPath(".work", "lunch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := Event{
+		Path: p,
+		Props: EventProperties{
+			Uid:     GenerateUid(),
+			Summary: "lunch",
+			Start:   start.Add(3 * time.Hour),
+			End:     start.Add(4 * time.Hour),
+		},
+	}
+	if err := added.Write(instance); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(backend.objects["/user/calendars/work/"]); n != 2 {
+		t.Fatalf("expected the new event to be created on the server, got %d objects", n)
+	}
+	if summary := remoteSummary(1); summary != "lunch" {
+		t.Errorf("expected the new event on the server, got '%s'", summary)
+	}
+
+	// Delete
+	if err := added.Delete(instance); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(backend.objects["/user/calendars/work/"]); n != 1 {
+		t.Errorf("expected the event to be deleted from the server, got %d objects", n)
+	}
+	if events := readCached(); len(events) != 1 {
+		t.Errorf("expected the event to be deleted from the cache, got %v", events)
+	}
+
+	// Read-only
+	source.Writable = false
+	instance.Config.Sources["work"] = source
+	if err := edited.Write(instance); err == nil {
+		t.Error("expected writing to a read-only source to fail")
+	}
+}
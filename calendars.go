@@ -0,0 +1,131 @@
+package ian
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CalendarSeparator separates the names of nested calendars, e.g. 'work/oncall' is nested in 'work'.
+// The calendars of a native source are nested in the source's cache calendar too (e.g. '.team:work' in '.team').
+const CalendarSeparator string = "/"
+
+// isValidCalendarName returns true if the calendar can be used as (a part of) a path.
+// Only the outermost calendar may start with a dot (like the cache calendars), since nested dot-directories are ignored.
+func isValidCalendarName(calendar string) bool {
+	illegalChars := "\n "
+	if filepath.Separator != '/' {
+		illegalChars += string(filepath.Separator)
+	}
+	if calendar == "" || strings.ContainsAny(calendar, illegalChars) {
+		return false
+	}
+	for i, part := range strings.Split(calendar, CalendarSeparator) {
+		if part == "" || part == "." || part == ".." || (i != 0 && strings.HasPrefix(part, ".")) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParentCalendar returns the calendar that the calendar is nested in.
+func ParentCalendar(calendar string) (string, bool) {
+	separators := CalendarSeparator
+	if strings.HasPrefix(calendar, ".") {
+		// Only the calendars of sources are nested in their cache calendar, since other calendars may contain the
+		// separator in their names.
+		separators += SourceCalendarSeparator
+	}
+	i := strings.LastIndexAny(calendar, separators)
+	if i <= 0 {
+		return "", false
+	}
+	return calendar[:i], true
+}
+
+// IsCalendarIn returns true if the calendar is the other calendar, or is nested in it.
+func IsCalendarIn(calendar, other string) bool {
+	for c, ok := calendar, true; ok; c, ok = ParentCalendar(c) {
+		if c == other {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCalendarInAny returns true if the calendar is, or is nested in, any of the calendars.
+// It is used to select calendars, so that e.g. 'work' also selects 'work/oncall'.
+func IsCalendarInAny(calendar string, calendars []string) bool {
+	for _, other := range calendars {
+		if IsCalendarIn(calendar, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// CommonCalendar returns the innermost calendar that both calendars are, or are nested in.
+func CommonCalendar(calendar1, calendar2 string) (string, bool) {
+	for c, ok := calendar1, true; ok; c, ok = ParentCalendar(c) {
+		if IsCalendarIn(calendar2, c) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// readCalendarDirs returns the calendars in the directory (e.g. the root), including the calendars nested in them,
+// like 'work' and 'work/oncall'. Outermost files are warned about, since the root should only contain calendars.
+func readCalendarDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	calendars := []string{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if !entry.IsDir() {
+			log.Printf("warning: ignoring file '%s'. the root directory should only contain calendars (directories). any other files/directories should be prefixed with a dot ('.').\n", filepath.Join(dir, entry.Name()))
+			continue
+		}
+		calendars = append(calendars, entry.Name())
+
+		nested, err := readNestedCalendarDirs(filepath.Join(dir, entry.Name()), entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, nested...)
+	}
+
+	return calendars, nil
+}
+
+// readNestedCalendarDirs returns the calendars nested in the calendar's directory.
+func readNestedCalendarDirs(dir, calendar string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	calendars := []string{}
+	for _, entry := range entries {
+		// The files are the calendar's events and to-dos.
+		if strings.HasPrefix(entry.Name(), ".") || !entry.IsDir() {
+			continue
+		}
+		nestedCalendar := calendar + CalendarSeparator + entry.Name()
+		calendars = append(calendars, nestedCalendar)
+
+		nested, err := readNestedCalendarDirs(filepath.Join(dir, entry.Name()), nestedCalendar)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, nested...)
+	}
+
+	return calendars, nil
+}
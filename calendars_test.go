@@ -0,0 +1,87 @@
+package ian
+
+import (
+	"image/color"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNestedCalendars(t *testing.T) {
+	instance := &Instance{Root: t.TempDir()}
+	instance.Config.Calendars = map[string]CalendarConfig{
+		"work":          {Color: color.RGBA{255, 0, 0, 255}},
+		"work/platform": {},
+	}
+
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	for _, calendar := range []string{"work", "work/platform/oncall", "home"} {
+		props := EventProperties{Uid: GenerateUid(), Summary: calendar, Start: start, End: start.Add(time.Hour)}
+		if err := props.Write(filepath.Join(instance.Root, filepath.FromSlash(calendar), "event")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := instance.ReadEventFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	calendars := []string{}
+	for _, event := range events {
+		if event.Path.Calendar() != event.Props.Summary {
+			t.Errorf("expected '%s' to be in calendar '%s'", event.Path, event.Props.Summary)
+		}
+		if IsCalendarInAny(event.Path.Calendar(), []string{"work"}) {
+			calendars = append(calendars, event.Path.Calendar())
+		}
+	}
+	slices.Sort(calendars)
+	if !slices.Equal(calendars, []string{"work", "work/platform/oncall"}) {
+		t.Errorf("expected 'work' to select its nested calendars, got %v", calendars)
+	}
+
+	conf, err := instance.Config.GetContainerConfig("work/platform/oncall")
+	if err != nil || conf.Color != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the color of 'work' to be inherited, got %v (%v)", conf, err)
+	}
+
+	legend := DisplayCalendarLegend(instance, events)
+	if !strings.Contains(legend, "  \033[38;2;255;0;0m▆ platform/oncall") {
+		t.Errorf("expected the nested calendar to be shown under its calendar, got %q", legend)
+	}
+}
+
+func TestCalendarNames(t *testing.T) {
+	for calendar, valid := range map[string]bool{
+		"work":                 true,
+		"work/platform/oncall": true,
+		".team:work/oncall":    true,
+		"work/":                false,
+		"work//oncall":         false,
+		"work/../home":         false,
+		"work/.hidden":         false,
+		"my work":              false,
+	} {
+		if _, err := NewEventPath(calendar, "event"); (err == nil) != valid {
+			t.Errorf("%s: expected valid to be %t, got %v", calendar, valid, err)
+		}
+	}
+
+	if p, err := ParseEventPath("work/oncall/Page someone"); err != nil || p.Calendar() != "work/oncall" || p.Name() != "Page someone" {
+		t.Errorf("unexpected parsed path %v (%v)", p, err)
+	}
+	if c, ok := CommonCalendar("work/platform/oncall", "work/design"); !ok || c != "work" {
+		t.Errorf("expected 'work' in common, got '%s'", c)
+	}
+	if _, ok := CommonCalendar("work/oncall", "home"); ok {
+		t.Error("expected nothing in common")
+	}
+	if c, ok := ParentCalendar(".team:work"); !ok || c != ".team" {
+		t.Errorf("expected the source calendar to be nested in '.team', got '%s'", c)
+	}
+	if IsCalendarIn("a:b", "a") {
+		t.Error("expected 'a:b' not to be nested in 'a'")
+	}
+}
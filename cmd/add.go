@@ -58,7 +58,13 @@ func addCmdRun(cmd *cobra.Command, args []string) {
 		log.Fatal("'end', 'hours' and 'duration' are mutually exclusive")
 	}
 
-	props.Start, err = ian.ParseDateTime(start, ian.GetTimeZone())
+	props.TimeZone, _ = eventFlags.GetString(eventFlag_TimeZone)
+	loc, err := parseTimeZone(props.TimeZone)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	props.Start, err = ian.ParseDateTime(start, loc)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -66,7 +72,7 @@ func addCmdRun(cmd *cobra.Command, args []string) {
 	switch {
 	case end != "":
 		var err error
-		props.End, err = ian.ParseDateTime(end, ian.GetTimeZone())
+		props.End, err = ian.ParseDateTime(end, loc)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -91,6 +97,33 @@ func addCmdRun(cmd *cobra.Command, args []string) {
 	props.Recurrence.RDate, _ = eventFlags.GetString(eventFlag_Rdate)
 	props.Recurrence.ExDate, _ = eventFlags.GetString(eventFlag_ExDate)
 
+	status, _ := eventFlags.GetString(eventFlag_Status)
+	transparency, _ := eventFlags.GetString(eventFlag_Transparency)
+	props.Status, props.Transparency, err = parseStatusAndTransparency(status, transparency)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	props.Categories, _ = eventFlags.GetStringSlice(eventFlag_Category)
+
+	alarms, _ := eventFlags.GetStringSlice(eventFlag_Alarm)
+	props.Alarms, err = parseAlarms(alarms)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	organizer, _ := eventFlags.GetString(eventFlag_Organizer)
+	props.Organizer, err = parseOrganizer(organizer)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	attendees, _ := eventFlags.GetStringArray(eventFlag_Attendee)
+	props.Attendees, err = parseAttendees(attendees)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	props.Uid = ian.GenerateUid()
 
 	now := time.Now().In(ian.GetTimeZone())
@@ -126,11 +159,14 @@ func addCmdRun(cmd *cobra.Command, args []string) {
 		props.End.Format(ian.DefaultTimeLayout),
 	)
 
-	instance.Sync(func() error {
+	err = instance.Sync(func() error {
 		return event.Write(instance)
 	}, ian.SyncEvent{
 		Type:    ian.SyncEventCreate,
 		Files:   []string{event.Path.Filepath(instance)},
 		Message: fmt.Sprintf("ian: create event '%s'", event.Path.String()),
 	}, false, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
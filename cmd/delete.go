@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/truecrunchyfrog/ian"
@@ -47,14 +49,14 @@ func deleteCmdRun(cmd *cobra.Command, args []string) {
 	}
 
 	for _, arg := range args {
-		event, err := ian.GetEvent(&events, arg)
+		event, err := ian.FindEvent(&events, arg)
 		if err != nil {
 			log.Fatal(err)
 		}
-		if event.Constant {
+		if event.Constant && (event.Type != ian.EventTypeRecurrence || event.Parent.Constant) {
 			log.Fatalf("'%s' is a constant event and cannot be deleted by itself.\n", event.Path)
 		}
-		if event.Props.Recurrence.IsThereRecurrence() {
+		if event.Props.Recurrence.IsThereRecurrence() && !event.Props.IsOverride() {
 			log.Printf("warning: '%s' is a recurring event and all recurrences will be deleted too.\n", event.Path)
 		}
 		if confirm, _ := cmd.Flags().GetBool("confirm"); confirm {
@@ -89,28 +91,73 @@ func deleteCmdRun(cmd *cobra.Command, args []string) {
 	}
 
 	filesToDelete := []string{}
+	// removals are the events behind filesToDelete.
+	removals := []*ian.Event{}
+	// excludingParents are recurring events that get exception dates for their deleted occurrences.
+	excludingParents := map[string]*ian.Event{}
 
 	for i, deleteEvent := range deleteEvents {
-		filesToDelete = append(filesToDelete, deleteEvent.Path.Filepath(instance))
-
 		if i != 0 {
 			syncMsg += ", "
 		}
 		syncMsg += "'" + deleteEvent.Path.String() + "'"
 
+		if deleteEvent.Type != ian.EventTypeRecurrence && !slices.Contains(filesToDelete, deleteEvent.Path.Filepath(instance)) {
+			filesToDelete = append(filesToDelete, deleteEvent.Path.Filepath(instance))
+			removals = append(removals, deleteEvent)
+		}
+
+		if deleteEvent.Type == ian.EventTypeRecurrence || deleteEvent.Props.IsOverride() && deleteEvent.Parent != nil {
+			// Deleting an occurrence (generated or overridden) excludes it from its parent.
+			parent, ok := excludingParents[deleteEvent.Parent.Path.String()]
+			if !ok {
+				p := *deleteEvent.Parent
+				parent = &p
+				excludingParents[parent.Path.String()] = parent
+			}
+			parent.Props.ExcludeOccurrence(deleteEvent.Props.RecurrenceId)
+		}
+
+		if deleteEvent.Props.Recurrence.IsThereRecurrence() && !deleteEvent.Props.IsOverride() {
+			// Deleting a recurring event deletes its overrides too.
+			for _, e := range events {
+				if e.Type != ian.EventTypeRecurrence && e.Props.IsOverride() && e.Parent != nil && e.Parent.Path.String() == deleteEvent.Path.String() && !slices.Contains(filesToDelete, e.Path.Filepath(instance)) {
+					filesToDelete = append(filesToDelete, e.Path.Filepath(instance))
+					removals = append(removals, &e)
+					fmt.Printf("deleted override '%s'\n", e.Path)
+				}
+			}
+		}
+
 		fmt.Printf("deleted event '%s'\n", deleteEvent.Path)
 	}
 
+	files := slices.Clone(filesToDelete)
+	for path, parent := range excludingParents {
+		if slices.Contains(filesToDelete, parent.Path.Filepath(instance)) {
+			// The whole recurring event is deleted anyway.
+			delete(excludingParents, path)
+			continue
+		}
+		parent.Props.Modified = time.Now().In(ian.GetTimeZone())
+		files = append(files, parent.Path.Filepath(instance))
+	}
+
 	err = instance.Sync(func() error {
-		for _, file := range filesToDelete {
-			if err := os.Remove(file); err != nil {
+		for _, e := range removals {
+			if err := e.Delete(instance); err != nil {
+				return err
+			}
+		}
+		for _, parent := range excludingParents {
+			if err := parent.Write(instance); err != nil {
 				return err
 			}
 		}
 		return nil
 	}, ian.SyncEvent{
 		Type:    ian.SyncEventDelete,
-		Files:   filesToDelete,
+		Files:   files,
 		Message: syncMsg,
 	}, false, nil)
 
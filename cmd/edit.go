@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -17,6 +18,8 @@ const tweakNameFlag string = "tweak-name"
 const noRenameFlag string = "no-rename"
 const copyFlag string = "copy"
 const updateUidFlag string = "update-uid"
+const removeAttendeeFlag string = "remove-attendee"
+const fromOccurrenceFlag string = "from-occurrence"
 
 var editFlags []string = []string{
 	renameFlag,
@@ -30,10 +33,18 @@ var editFlags []string = []string{
 	eventFlag_Url,
 	eventFlag_Duration,
 	eventFlag_Hours,
+	eventFlag_TimeZone,
 	eventFlag_Calendar,
 	eventFlag_Rrule,
 	eventFlag_Rdate,
 	eventFlag_ExDate,
+	eventFlag_Status,
+	eventFlag_Transparency,
+	eventFlag_Category,
+	eventFlag_Alarm,
+	eventFlag_Organizer,
+	eventFlag_Attendee,
+	removeAttendeeFlag,
 }
 
 func init() {
@@ -43,6 +54,9 @@ func init() {
 	editCmd.Flags().String(copyFlag, "", "Copy the event to the `destination` path, along with the changes.")
 	editCmd.Flags().Bool(updateUidFlag, false, "Update the UID of an event.")
 
+	editCmd.Flags().StringSlice(removeAttendeeFlag, nil, "Remove attendee(s) from the event by their `addresses`.")
+	editCmd.Flags().String(fromOccurrenceFlag, "", "Only edit the occurrences of a recurring event from this `date` and onward. The recurring event is split in two at the first occurrence on or after the date, and the changes are applied to the new, following event.")
+
 	editCmd.MarkFlagsMutuallyExclusive(renameFlag, noRenameFlag)
 
 	eventPropsCmd.AddCommand(editCmd)
@@ -90,7 +104,7 @@ func editCmdRun(cmd *cobra.Command, args []string) {
 	}
 
 	for _, arg := range args {
-		event, err := ian.GetEvent(&events, arg)
+		event, err := ian.FindEvent(&events, arg)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -101,7 +115,21 @@ func editCmdRun(cmd *cobra.Command, args []string) {
 		log.Fatal("no event to edit")
 	}
 
+	var splitFrom time.Time
+	if cmd.Flags().Changed(fromOccurrenceFlag) {
+		if len(editEvents) > 1 {
+			log.Fatal("'--from-occurrence' cannot be used on multiple events")
+		}
+		splitString, _ := cmd.Flags().GetString(fromOccurrenceFlag)
+		splitFrom, err = ian.ParseDateTime(splitString, ian.GetTimeZone())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	onWritten := []func(){}
+	// alsoWrite are events that are changed as a side effect, and written along with the edited events.
+	alsoWrite := []*ian.Event{}
 
 	files := []string{}
 
@@ -113,6 +141,67 @@ func editCmdRun(cmd *cobra.Command, args []string) {
 	}
 
 	for i, event := range editEvents {
+		if !splitFrom.IsZero() {
+			series := event
+			if event.Type == ian.EventTypeRecurrence || event.Props.IsOverride() && event.Parent != nil {
+				series = event.Parent
+			}
+			if series.Constant {
+				log.Fatalf("'%s' is a constant event and cannot be modified.\n", series.Path)
+			}
+			rruleSet, err := series.Props.GetRruleSet()
+			if err != nil {
+				log.Fatal(err)
+			}
+			occurrence := rruleSet.After(splitFrom, true)
+			if occurrence.IsZero() {
+				log.Fatalf("'%s' has no occurrence on or after %s.\n", series.Path, splitFrom.Format(ian.DefaultTimeLayout))
+			}
+
+			if occurrence.Equal(series.Props.Start) {
+				log.Printf("note: %s is the first occurrence of '%s', so the whole recurring event is edited.\n", occurrence.Format(ian.DefaultTimeLayout), series.Path)
+				s := *series
+				event = &s
+			} else {
+				original := *series
+				next, err := original.Props.SplitRecurrence(occurrence)
+				if err != nil {
+					log.Fatal(err)
+				}
+				nextEvent, err := instance.NewEvent(next, series.Path.Calendar())
+				if err != nil {
+					log.Fatal(err)
+				}
+				log.Printf("note: '%s' now ends before %s, and continues as '%s'.\n", original.Path, occurrence.Format(ian.DefaultTimeLayout), nextEvent.Path)
+				alsoWrite = append(alsoWrite, &original)
+				files = append(files, original.Path.Filepath(instance))
+
+				// Overrides of the following occurrences move along to the new event.
+				for _, e := range events {
+					if e.Type != ian.EventTypeRecurrence && e.Props.IsOverride() && e.Parent != nil && e.Parent.Path.String() == series.Path.String() && !e.Props.RecurrenceId.Before(occurrence) {
+						e.Props.Uid = next.Uid
+						alsoWrite = append(alsoWrite, &e)
+						files = append(files, e.Path.Filepath(instance))
+					}
+				}
+
+				// The original occurrences from the split and onward are replaced, and cannot collide.
+				events = ian.FilterEvents(&events, func(e *ian.Event) bool {
+					return e.Props.Uid != original.Props.Uid || e.Props.Start.Before(occurrence)
+				})
+
+				event = &nextEvent
+			}
+			editEvents[i] = event
+		} else if event.Type == ian.EventTypeRecurrence && !event.Parent.Constant {
+			// Editing a generated occurrence only changes that occurrence, by overriding it.
+			override, err := instance.NewOverride(event)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("note: '%s' is a recurrence of '%s', and is overridden by '%s'.\n", event.Path, event.Parent.Path, override.Path)
+			*event = override
+		}
 		if event.Constant {
 			log.Fatalf("'%s' is a constant event and cannot be modified.\n", event.Path)
 		}
@@ -123,9 +212,13 @@ func editCmdRun(cmd *cobra.Command, args []string) {
 		}
 		syncMsg += "'" + event.Path.String() + "'"
 
+		_, isSourceEvent := ian.SourceOfCalendar(event.Path.Calendar())
+		unedited := *event
+
 		if eventFlags.Changed(eventFlag_Summary) { // Summary
 			event.Props.Summary, _ = eventFlags.GetString(eventFlag_Summary)
-			if noRename, _ := cmd.Flags().GetBool(noRenameFlag); !noRename && !cmd.Flags().Changed(renameFlag) {
+			// Source events are not renamed, since the names of their files are given when they are cached.
+			if noRename, _ := cmd.Flags().GetBool(noRenameFlag); !noRename && !isSourceEvent && !cmd.Flags().Changed(renameFlag) {
 				log.Println("summary change will cause file rename. '--no-rename' would prevent this.")
 				cmd.Flags().Set(renameFlag, event.Props.FormatName())
 				cmd.Flags().Set(tweakNameFlag, "1")
@@ -135,6 +228,9 @@ func editCmdRun(cmd *cobra.Command, args []string) {
 			if len(editEvents) > 1 {
 				log.Fatal("rename cannot be used on multiple events")
 			}
+			if isSourceEvent {
+				log.Fatalf("'%s' is in a source and cannot be renamed.\n", event.Path)
+			}
 			newName, _ := cmd.Flags().GetString(renameFlag)
 			var newPath ian.EventPath
       var err error
@@ -179,9 +275,18 @@ func editCmdRun(cmd *cobra.Command, args []string) {
 		if cmd.Flags().Changed(updateUidFlag) { // UID
 			event.Props.Uid = ian.GenerateUid()
 		}
+		if eventFlags.Changed(eventFlag_TimeZone) { // Time zone
+			event.Props.TimeZone, _ = eventFlags.GetString(eventFlag_TimeZone)
+			loc, err := parseTimeZone(event.Props.TimeZone)
+			if err != nil {
+				log.Fatal(err)
+			}
+			event.Props.Start = event.Props.Start.In(loc)
+			event.Props.End = event.Props.End.In(loc)
+		}
 		if eventFlags.Changed(eventFlag_Start) { // Start
 			startString, _ := eventFlags.GetString(eventFlag_Start)
-			start, err := ian.ParseDateTime(startString, ian.GetTimeZone())
+			start, err := ian.ParseDateTime(startString, event.Props.GetTimeZoneLocation())
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -189,7 +294,7 @@ func editCmdRun(cmd *cobra.Command, args []string) {
 		}
 		if eventFlags.Changed(eventFlag_End) { // End
 			endString, _ := eventFlags.GetString(eventFlag_End)
-			end, err := ian.ParseDateTime(endString, ian.GetTimeZone())
+			end, err := ian.ParseDateTime(endString, event.Props.GetTimeZoneLocation())
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -225,7 +330,10 @@ func editCmdRun(cmd *cobra.Command, args []string) {
 				log.Fatalf("a file with the path '%s' already exists.\n", event.Path)
 			}
 			onWritten = append(onWritten, func() {
-				os.Remove(oldPath.Filepath(instance))
+				// Moving out of a source deletes the event from the source.
+				if err := unedited.Delete(instance); err != nil && !os.IsNotExist(err) {
+					log.Printf("warning: failed to delete '%s': %s\n", unedited.Path, err)
+				}
 			})
 			log.Printf("note: '%s' is being moved to '%s'.\n", oldPath, event.Path)
 		}
@@ -242,6 +350,63 @@ func editCmdRun(cmd *cobra.Command, args []string) {
 			event.Props.Recurrence.ExDate = recurrenceFlag
 		}
 
+		if eventFlags.Changed(eventFlag_Status) { // Status
+			status, _ := eventFlags.GetString(eventFlag_Status)
+			event.Props.Status, _, err = parseStatusAndTransparency(status, "")
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if eventFlags.Changed(eventFlag_Transparency) { // Transparency
+			transparency, _ := eventFlags.GetString(eventFlag_Transparency)
+			_, event.Props.Transparency, err = parseStatusAndTransparency("", transparency)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if eventFlags.Changed(eventFlag_Category) { // Categories
+			event.Props.Categories, _ = eventFlags.GetStringSlice(eventFlag_Category)
+		}
+		if eventFlags.Changed(eventFlag_Alarm) { // Alarms
+			alarms, _ := eventFlags.GetStringSlice(eventFlag_Alarm)
+			event.Props.Alarms, err = parseAlarms(alarms)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if eventFlags.Changed(eventFlag_Organizer) { // Organizer
+			organizer, _ := eventFlags.GetString(eventFlag_Organizer)
+			event.Props.Organizer, err = parseOrganizer(organizer)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if cmd.Flags().Changed(removeAttendeeFlag) { // Remove attendees
+			addresses, _ := cmd.Flags().GetStringSlice(removeAttendeeFlag)
+			for _, address := range addresses {
+				if event.Props.GetAttendee(address) == nil {
+					log.Fatalf("'%s' is not an attendee of '%s'.\n", address, event.Path)
+				}
+				event.Props.Attendees = slices.DeleteFunc(event.Props.Attendees, func(a ian.Attendee) bool {
+					return ian.IsSameCalendarAddress(a.Address, address)
+				})
+			}
+		}
+		if eventFlags.Changed(eventFlag_Attendee) { // Add attendees
+			attendeeFlags, _ := eventFlags.GetStringArray(eventFlag_Attendee)
+			attendees, err := parseAttendees(attendeeFlags)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, attendee := range attendees {
+				if event.Props.GetAttendee(attendee.Address) != nil {
+					log.Fatalf("'%s' is already an attendee of '%s'.\n", attendee.Address, event.Path)
+				}
+				event.Props.Attendees = append(event.Props.Attendees, attendee)
+			}
+		}
+
 		event.Props.Modified = time.Now().In(ian.GetTimeZone())
 
 		if err := event.Props.Validate(); err != nil {
@@ -254,6 +419,11 @@ func editCmdRun(cmd *cobra.Command, args []string) {
 	syncMsg += "; " + strings.Join(modified, ", ")
 
 	err = instance.Sync(func() error {
+		for _, event := range alsoWrite {
+			if err := event.Write(instance); err != nil {
+				log.Fatal(err)
+			}
+		}
 		for _, event := range editEvents {
 			if err := event.Write(instance); err != nil {
 				log.Fatal(err)
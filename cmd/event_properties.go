@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -18,6 +20,14 @@ const eventFlag_Location = "location"
 const eventFlag_Url = "url"
 const eventFlag_Duration = "duration"
 const eventFlag_Hours = "hours"
+const eventFlag_TimeZone = "tz"
+
+const eventFlag_Status = "status"
+const eventFlag_Transparency = "transparency"
+const eventFlag_Category = "category"
+const eventFlag_Alarm = "alarm"
+const eventFlag_Organizer = "organizer"
+const eventFlag_Attendee = "attendee"
 
 const eventFlag_Rrule = "rrule"
 const eventFlag_Rdate = "rdate"
@@ -35,6 +45,7 @@ func init() {
 
 	eventFlags.StringP(eventFlag_Start, "s", "", "Start date.")
 	eventFlags.StringP(eventFlag_End, "e", "", "End date.")
+	eventFlags.String(eventFlag_TimeZone, "", "The IANA time zone that the event is planned in (e.g. 'Europe/Stockholm'). Start and end dates are given in it, and recurrences follow its daylight saving time. When editing without a new start or end, the event keeps its time; use '--tz=' to remove the time zone.")
 	eventFlags.String(eventFlag_Rrule, "", "An RRULE expression according to iCalendar RFC 5545.")
 	eventFlags.String(eventFlag_Rdate, "", "An RDATE expression according to iCalendar RFC 5545.")
 	eventFlags.String(eventFlag_ExDate, "", "An EXDATE expression according to iCalendar RFC 5545.")
@@ -43,6 +54,14 @@ func init() {
 	eventFlags.StringP(eventFlag_Location, "l", "", "Where the event is taking place (e.g. address).")
 	eventFlags.StringP(eventFlag_Url, "u", "", "A URL relevant to the event.")
 
+	eventFlags.String(eventFlag_Status, "", "Status of the event: 'tentative', 'confirmed' or 'cancelled'.")
+	eventFlags.String(eventFlag_Transparency, "", "Whether the event blocks time: 'opaque' (busy, default) or 'transparent' (free). Transparent events never collide.")
+	eventFlags.StringSlice(eventFlag_Category, nil, "Categories of the event (e.g. '--category work,meeting'). When editing, the categories are replaced; use '--category=' to remove them.")
+	eventFlags.StringSlice(eventFlag_Alarm, nil, "Reminder(s) for the event. Either a duration relative to the start (e.g. '--alarm -15m' for 15 minutes before), or a datetime. When editing, the alarms are replaced; use '--alarm=' to remove them.")
+
+	eventFlags.String(eventFlag_Organizer, "", "The organizer of the event, like 'Joe <joe@example.com>'.")
+	eventFlags.StringArray(eventFlag_Attendee, nil, "Invite an attendee to the event, like 'Joe <joe@example.com>'. Repeat the flag for more attendees. When editing, the attendees are added to the existing ones.")
+
 	eventFlags.DurationP(eventFlag_Duration, "d", 0, "Duration of the event from start to end. Use instead of providing the 'end' argument. Example: '--duration 1h30m' to set 'end' to 'start' with 1 hour and 30 minutes added.")
 	eventFlags.StringSliceP(eventFlag_Hours, "H", nil, "Time of the day(s). E.g.: '-h 09:00,17:00' to complement the start date with the time '09:00' and set the 'end' date to the same day but with the time '17:00', or '-h 22:00,05:00' to complement the start date with the time '22:00' and set the 'end' date to the day after with the time '05:00'. The second time parameter can be replaced with a duration, like in --duration.")
 
@@ -53,6 +72,18 @@ func init() {
 	rootCmd.AddCommand(eventPropsCmd)
 }
 
+// parseTimeZone loads an IANA time zone. An empty name is the global time zone.
+func parseTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return ian.GetTimeZone(), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone '%s'", name)
+	}
+	return loc, nil
+}
+
 func handleHours(hours []string, startDate *time.Time, endDate *time.Time) error {
 	if len(hours) != 2 {
 		return errors.New("'hours' must have exactly two parameters, like: '--hours 09:00,17:00'.")
@@ -107,3 +138,83 @@ func handleHours(hours []string, startDate *time.Time, endDate *time.Time) error
 
 	return nil
 }
+
+// parseAlarms parses alarm flag values. An alarm is either a duration relative to the event start, or an absolute datetime.
+func parseAlarms(inputs []string) ([]ian.Alarm, error) {
+	var alarms []ian.Alarm
+
+	for _, input := range inputs {
+		alarm := ian.Alarm{
+			Action: ian.AlarmActionDisplay,
+		}
+
+		if d, err := time.ParseDuration(input); err == nil {
+			alarm.Trigger = d
+		} else if t, err := ian.ParseDateTime(input, ian.GetTimeZone()); err == nil {
+			alarm.TriggerAt = t
+		} else {
+			return nil, fmt.Errorf("alarm '%s' is neither a duration nor a datetime", input)
+		}
+
+		alarms = append(alarms, alarm)
+	}
+
+	return alarms, nil
+}
+
+// parseAttendees parses attendee flag values into required participants that are expected to reply.
+func parseAttendees(inputs []string) ([]ian.Attendee, error) {
+	var attendees []ian.Attendee
+
+	for _, input := range inputs {
+		address, name, err := ian.ParseCalendarAddress(input)
+		if err != nil {
+			return nil, err
+		}
+
+		attendees = append(attendees, ian.Attendee{
+			Address: address,
+			Name:    name,
+			Role:    "REQ-PARTICIPANT",
+			Status:  ian.PartStatNeedsAction,
+			Rsvp:    true,
+		})
+	}
+
+	return attendees, nil
+}
+
+func parseOrganizer(input string) (ian.Organizer, error) {
+	if input == "" {
+		return ian.Organizer{}, nil
+	}
+
+	address, name, err := ian.ParseCalendarAddress(input)
+	if err != nil {
+		return ian.Organizer{}, err
+	}
+
+	return ian.Organizer{
+		Address: address,
+		Name:    name,
+	}, nil
+}
+
+// parseStatusAndTransparency parses the status and transparency flag values. Empty values are kept empty.
+func parseStatusAndTransparency(status, transparency string) (ian.EventStatus, ian.Transparency, error) {
+	s := ian.EventStatus(strings.ToUpper(status))
+	switch s {
+	case "", ian.EventStatusTentative, ian.EventStatusConfirmed, ian.EventStatusCancelled:
+	default:
+		return "", "", fmt.Errorf("invalid status '%s'. expected 'tentative', 'confirmed' or 'cancelled'.", status)
+	}
+
+	t := ian.Transparency(strings.ToUpper(transparency))
+	switch t {
+	case "", ian.TransparencyOpaque, ian.TransparencyTransparent:
+	default:
+		return "", "", fmt.Errorf("invalid transparency '%s'. expected 'opaque' or 'transparent'.", transparency)
+	}
+
+	return s, t, nil
+}
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"log"
-	"slices"
 	"strings"
 	"time"
 
@@ -12,18 +11,22 @@ import (
 )
 
 func init() {
-	findCmd.Flags().StringSliceP("calendars", "c", nil, "Events must be located in a calendar listed here.")
+	findCmd.Flags().StringSliceP("calendars", "c", nil, "Events must be located in a calendar listed here, or in a calendar nested in one (e.g. 'work' includes 'work/oncall').")
 	findCmd.Flags().BoolP("ignore-constant", "i", false, "Ignore constant events (cache and recurrences).")
 	findCmd.Flags().Bool("case-sensitive", false, "Query matching is sensitive to casing.")
 	findCmd.Flags().StringP("path", "p", "", "Query the events' paths.")
 	findCmd.Flags().StringP("summary", "s", "", "Query the events' summary.")
 	findCmd.Flags().StringP("description", "d", "", "Query the events' description.")
 
+	findCmd.Flags().StringSlice("category", nil, "Events must be in any of these categories.")
+	findCmd.Flags().Bool("all-categories", false, "When combined with 'category', events must be in all of the categories instead of any.")
+
 	findCmd.Flags().StringSlice("at", nil, "Events must occur during this/these datetime(s).")
 	findCmd.Flags().String("before", "", "Events must end before this datetime.")
 	findCmd.Flags().String("after", "", "Events must start after this datetime.")
 	findCmd.Flags().Bool("exclusive", false, "When combined with 'before' and/or 'after', the entire event time ranges must occur outside of these limits (e.g., if 'before' is set to 01-04-1991, then an event cannot start before and end after 1 April; the entire time range must be confined before that datetime).")
   findCmd.Flags().BoolP("one", "1", false, "Exit if the query does not match exactly one event.")
+	findCmd.Flags().IntP("limit", "n", 0, "Show at most this many events (the earliest ones). 0 shows all.")
 
 	findCmd.MarkFlagsMutuallyExclusive("at", "before")
 	findCmd.MarkFlagsMutuallyExclusive("at", "after")
@@ -45,7 +48,7 @@ func findCmdRun(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 
-	events, _, err := instance.ReadEvents(ian.TimeRange{})
+	events, err := instance.ReadEventFiles()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -56,6 +59,8 @@ func findCmdRun(cmd *cobra.Command, args []string) {
 	path, _ := cmd.Flags().GetString("path")
 	summary, _ := cmd.Flags().GetString("summary")
 	description, _ := cmd.Flags().GetString("description")
+	categories, _ := cmd.Flags().GetStringSlice("category")
+	allCategories, _ := cmd.Flags().GetBool("all-categories")
 
 	occurAt := []time.Time{}
 	occurBefore := time.Time{}
@@ -86,15 +91,53 @@ func findCmdRun(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	events = ian.FilterEvents(&events, func(e *ian.Event) bool {
-		if ignoreConstant && e.Constant {
+	// The time range only limits the recurrences that are generated, the events are matched exactly below.
+	var timeRange ian.TimeRange
+	for _, at := range occurAt {
+		if timeRange.From.IsZero() || at.Before(timeRange.From) {
+			timeRange.From = at
+		}
+		if at.After(timeRange.To) {
+			timeRange.To = at
+		}
+	}
+	if !occurAfter.IsZero() {
+		timeRange.From = occurAfter
+	}
+	if !occurBefore.IsZero() {
+		timeRange.To = occurBefore
+	}
+	if timeRange.To.IsZero() {
+		// Recurrences are generated until the end of the latest event.
+		timeRange.To = ian.GetEventsTimeRange(events).To
+	}
+	// Recurrences are only generated from the start of the time range (see ian.NewEventIterator), if it has one.
+	if !timeRange.From.IsZero() {
+		timeRange.From = timeRange.From.Add(-time.Nanosecond)
+	}
+	timeRange.To = timeRange.To.Add(time.Nanosecond)
+
+	match := func(e *ian.Event) bool {
+		if e.Replaced || ignoreConstant && e.Constant {
 			return false
 		}
 
-		if len(calendars) != 0 && !slices.Contains(calendars, e.Path.Calendar()) {
+		if len(calendars) != 0 && !ian.IsCalendarInAny(e.Path.Calendar(), calendars) {
 			return false
 		}
 
+		if len(categories) != 0 {
+			matches := 0
+			for _, category := range categories {
+				if e.Props.HasCategory(category) {
+					matches++
+				}
+			}
+			if matches == 0 || (allCategories && matches != len(categories)) {
+				return false
+			}
+		}
+
 		if len(occurAt) != 0 {
 			insideOne := false
 			for _, at := range occurAt {
@@ -146,13 +189,36 @@ func findCmdRun(cmd *cobra.Command, args []string) {
 		}
 
 		return true
-	})
+	}
+
+	one, _ := cmd.Flags().GetBool("one")
+	limit, _ := cmd.Flags().GetInt("limit")
+
+	// The events are matched as they are read, in order of their start, so the iteration can stop at the limit.
+	var found []ian.Event
+	count := 0
+	it := ian.NewEventIterator(events, timeRange)
+	for it.Next() && (limit == 0 || count < limit) {
+		event := it.Event()
+		if !match(&event) {
+			continue
+		}
+		count++
+		if one {
+			// Only shown if it is the only one.
+			if count == 1 {
+				found = append(found, event)
+			}
+			continue
+		}
+		fmt.Println(event.Path)
+	}
 
-  if one, _ := cmd.Flags().GetBool("one"); one && len(events) != 1 {
-    log.Fatalf("expected one result, got %d\n", len(events))
+  if one && count != 1 {
+    log.Fatalf("expected one result, got %d\n", count)
   }
 
-	for _, event := range events {
+	for _, event := range found {
 		fmt.Println(event.Path)
 	}
 }
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/truecrunchyfrog/ian"
+)
+
+func init() {
+	freeBusyCmd.Flags().StringSliceP("calendars", "c", nil, "Only consider events in the calendars in this `list`. Sources are included by their cache calendar (e.g. '.joe'). Nested calendars are included with their calendar.")
+	freeBusyCmd.Flags().Bool("ical", false, "Output a VFREEBUSY iCalendar instead of a list.")
+	freeBusyCmd.Flags().BoolP("busy", "b", false, "List the busy periods instead of the free ones.")
+	freeBusyCmd.Flags().StringSlice("working-hours", []string{"08:00", "17:00"}, "The start and end of the working day. Free time is only listed within these hours.")
+	freeBusyCmd.Flags().IntSlice("workdays", []int{2, 3, 4, 5, 6}, "The days of the week to list free time for, by index (1 = Sunday, 2 = Monday, ... 7 = Saturday).")
+	freeBusyCmd.Flags().Duration("min-gap", 30*time.Minute, "Leave out free time shorter than this.")
+	viper.BindPFlag("working-hours", freeBusyCmd.Flags().Lookup("working-hours"))
+	viper.BindPFlag("workdays", freeBusyCmd.Flags().Lookup("workdays"))
+
+	freeBusyCmd.MarkFlagsMutuallyExclusive("ical", "busy")
+
+	rootCmd.AddCommand(freeBusyCmd)
+}
+
+var freeBusyCmd = &cobra.Command{
+	Use:     "freebusy [from [to]]",
+	Aliases: []string{"fb", "free", "availability"},
+	Short:   "Show free/busy time without event details",
+	Long:    "Show when you are free (or busy), without exposing any event details. Only busy events count; transparent and cancelled events are free time. Without any arguments, 'from' is today, and 'to' is one week ahead.",
+	Args:    cobra.RangeArgs(0, 2),
+	Run:     freeBusyCmdRun,
+}
+
+func freeBusyCmdRun(cmd *cobra.Command, args []string) {
+	var timeRange ian.TimeRange
+	var err error
+
+	now := time.Now().In(ian.GetTimeZone())
+
+	if len(args) >= 1 {
+		timeRange.From, err = ian.ParseDateTime(args[0], ian.GetTimeZone())
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		timeRange.From = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, ian.GetTimeZone())
+	}
+
+	if len(args) >= 2 {
+		timeRange.To, err = ian.ParseDateTime(args[1], ian.GetTimeZone())
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		timeRange.To = timeRange.From.AddDate(0, 0, 7)
+	}
+
+	if !timeRange.From.Before(timeRange.To) {
+		log.Fatal("'from' must be before 'to'")
+	}
+
+	instance, err := ian.CreateInstance(GetRoot())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	events, _, err := instance.ReadEvents(timeRange)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cals, _ := cmd.Flags().GetStringSlice("calendars"); len(cals) != 0 {
+		events = ian.FilterEvents(&events, func(e *ian.Event) bool {
+			return ian.IsCalendarInAny(e.Path.Calendar(), cals)
+		})
+	}
+
+	busy := ian.GetBusyPeriods(events, timeRange)
+
+	if outputIcal, _ := cmd.Flags().GetBool("ical"); outputIcal {
+		out, err := ian.SerializeIcal(ian.ToIcalFreeBusy(busy, timeRange))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(out.String())
+		return
+	}
+
+	var lastShownDate time.Time
+
+	if listBusy, _ := cmd.Flags().GetBool("busy"); listBusy {
+		if len(busy) == 0 {
+			fmt.Println("\033[2;3mNot busy.\033[0m")
+		}
+		for _, period := range busy {
+			var suffix string
+			if period.Type == ian.BusyTypeBusyTentative {
+				suffix = " \033[2m(tentative)\033[0m"
+			}
+			fmt.Println(displayPeriod(period.TimeRange, &lastShownDate) + suffix)
+		}
+		return
+	}
+
+	hours := viper.GetStringSlice("working-hours")
+	if len(hours) != 2 {
+		log.Fatal("'working-hours' must have exactly two parameters, like: '--working-hours 09:00,17:00'.")
+	}
+	dayStart, err := parseTimeOfDay(hours[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	dayEnd, err := parseTimeOfDay(hours[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dayEnd <= dayStart {
+		log.Fatal("the working day must end after it starts")
+	}
+
+	workdays := []time.Weekday{}
+	for _, i := range viper.GetIntSlice("workdays") {
+		if i < 1 || i > 7 {
+			log.Fatalf("invalid workday index %d (must be within the bounds of 1-7)\n", i)
+		}
+		workdays = append(workdays, time.Weekday(i-1))
+	}
+
+	minGap, _ := cmd.Flags().GetDuration("min-gap")
+
+	free := ian.GetFreePeriods(busy, timeRange, dayStart, dayEnd, workdays, minGap, ian.GetTimeZone())
+
+	if len(free) == 0 {
+		fmt.Println("\033[2;3mNo free time.\033[0m")
+	}
+	for _, period := range free {
+		fmt.Println(displayPeriod(period, &lastShownDate))
+	}
+}
+
+// parseTimeOfDay parses a time like '09:00' into the duration since midnight.
+func parseTimeOfDay(input string) (time.Duration, error) {
+	t, err := ian.ParseTimeOnly(input)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// displayPeriod formats a period as a line, with the date only shown when it differs from the last shown date.
+func displayPeriod(period ian.TimeRange, lastShownDate *time.Time) string {
+	from := period.From.In(ian.GetTimeZone())
+	to := period.To.In(ian.GetTimeZone())
+
+	var date string
+	if from.YearDay() != lastShownDate.YearDay() || from.Year() != lastShownDate.Year() {
+		date = from.Format("Mon _2 Jan")
+		*lastShownDate = from
+	}
+
+	toFmt := to.Format("15:04")
+	if to.YearDay() != from.YearDay() || to.Year() != from.Year() {
+		toFmt = to.Format("Mon _2 Jan 15:04")
+	}
+
+	return fmt.Sprintf("\033[2m%-10s\033[0m \033[1m%s 🡲  %s\033[0m \033[2m(%s)\033[0m", date, from.Format("15:04"), toFmt, ian.DurationToString(to.Sub(from)))
+}
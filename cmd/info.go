@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/truecrunchyfrog/ian"
@@ -45,7 +46,7 @@ func infoCmdRun(cmd *cobra.Command, args []string) {
 	}
 
 	for _, arg := range args {
-		event, err := ian.GetEvent(&events, arg)
+		event, err := ian.FindEvent(&events, arg)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -65,7 +66,15 @@ func infoCmdRun(cmd *cobra.Command, args []string) {
 			{"start", event.Props.Start},
 			{"end", event.Props.End},
 			{"duration", ian.DurationToString(event.Props.End.Sub(event.Props.Start))},
+			{"timezone", event.Props.TimeZone},
 			{"recurrence", event.Props.Recurrence},
+			{"overrides", displayRecurrenceId(&event.Props)},
+			{"status", strings.ToLower(string(event.Props.Status))},
+			{"transp", strings.ToLower(string(event.Props.Transparency))},
+			{"categories", strings.Join(event.Props.Categories, ", ")},
+			{"alarms", displayAlarms(&event.Props)},
+			{"organizer", displayOrganizer(event.Props.Organizer)},
+			{"attendees", displayAttendees(event.Props.Attendees)},
 			{"", ""},
 			{"description", event.Props.Description},
 			{"location", event.Props.Location},
@@ -75,6 +84,7 @@ func infoCmdRun(cmd *cobra.Command, args []string) {
 			{"modified", event.Props.Modified},
 			{"", ""},
 			{"uid", event.Props.Uid},
+			{"related", event.Props.RelatedTo},
 		}
 
 		for _, keyValue := range pairs {
@@ -86,3 +96,42 @@ func infoCmdRun(cmd *cobra.Command, args []string) {
 func DisplayKeyValue(key string, value any) string {
 	return fmt.Sprintf("\033[2m%-10s\033[0m %v", key, value)
 }
+
+func displayAlarms(props *ian.EventProperties) string {
+	var output string
+	for i, alarm := range props.Alarms {
+		if i != 0 {
+			output += "\n" + fmt.Sprintf("%-10s ", "")
+		}
+		output += alarm.String()
+		if alarm.TriggerAt.IsZero() {
+			output += " \033[2m(" + alarm.Time(props).Format(ian.DefaultTimeLayout) + ")\033[0m"
+		}
+	}
+	return output
+}
+
+func displayRecurrenceId(props *ian.EventProperties) string {
+	if !props.IsOverride() {
+		return ""
+	}
+	return "occurrence at " + props.RecurrenceId.Format(ian.DefaultTimeLayout)
+}
+
+func displayOrganizer(organizer ian.Organizer) string {
+	if organizer.Address == "" {
+		return ""
+	}
+	return ian.Attendee{Address: organizer.Address, Name: organizer.Name}.String()
+}
+
+func displayAttendees(attendees []ian.Attendee) string {
+	var output string
+	for i, attendee := range attendees {
+		if i != 0 {
+			output += "\n" + fmt.Sprintf("%-10s ", "")
+		}
+		output += attendee.String()
+	}
+	return output
+}
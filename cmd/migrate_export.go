@@ -51,36 +51,36 @@ var migrateExportCmd = &cobra.Command{
 }
 
 func migrateExportCmdRun(cmd *cobra.Command, args []string) {
-	var filterFunc func(e *ian.Event) bool
+	var filterFunc func(path ian.EventPath, cached bool) bool
 
 	switch {
 	case includeCache:
-		filterFunc = func(e *ian.Event) bool {
+		filterFunc = func(path ian.EventPath, cached bool) bool {
 			return true
 		}
 	case cherrypickCalendars != nil:
-		filterFunc = func(e *ian.Event) bool {
+		filterFunc = func(path ian.EventPath, cached bool) bool {
 			// Only from these calendars.
-			return slices.Contains(cherrypickCalendars, e.Path.Calendar())
+			return ian.IsCalendarInAny(path.Calendar(), cherrypickCalendars)
 		}
 	case cherrypickEvents != nil:
-		filterFunc = func(e *ian.Event) bool {
+		filterFunc = func(path ian.EventPath, cached bool) bool {
 			// Only these events.
-			return slices.Contains(cherrypickEvents, e.Path.String())
+			return slices.Contains(cherrypickEvents, path.String())
 		}
 	case excludeCalendars != nil:
-		filterFunc = func(e *ian.Event) bool {
+		filterFunc = func(path ian.EventPath, cached bool) bool {
 			// NOT these calendars.
-			return !slices.Contains(excludeCalendars, e.Path.Calendar())
+			return !ian.IsCalendarInAny(path.Calendar(), excludeCalendars)
 		}
 	case excludeEvents != nil:
-		filterFunc = func(e *ian.Event) bool {
+		filterFunc = func(path ian.EventPath, cached bool) bool {
 			// NOT these events.
-			return !slices.Contains(cherrypickEvents, e.Path.String())
+			return !slices.Contains(cherrypickEvents, path.String())
 		}
 	default:
-		filterFunc = func(e *ian.Event) bool {
-			return e.Type != ian.EventTypeCache
+		filterFunc = func(path ian.EventPath, cached bool) bool {
+			return !cached
 		}
 	}
 
@@ -95,12 +95,21 @@ func migrateExportCmdRun(cmd *cobra.Command, args []string) {
 	}
 
 	events = ian.FilterEvents(&events, func(e *ian.Event) bool {
-		return e.Type != ian.EventTypeRecurrence && filterFunc(e)
+		return e.Type != ian.EventTypeRecurrence && filterFunc(e.Path, e.Type == ian.EventTypeCache)
+	})
+
+	todos, err := instance.ReadTodos()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	todos = ian.FilterTodos(&todos, func(t *ian.Todo) bool {
+		return filterFunc(t.Path, t.Constant)
 	})
 
 	if cmd.Flags().Changed("file") {
 		fileDest, _ := cmd.Flags().GetString("file")
-		ics := ian.ToIcal(events, "")
+		ics := ian.ToIcal(events, todos, "")
     out, err := ian.SerializeIcal(ics)
     if err != nil {
       log.Fatal(err)
@@ -123,7 +132,18 @@ func migrateExportCmdRun(cmd *cobra.Command, args []string) {
       eventsByCal[cal] = append(calEvents, event)
 		}
 
+		todosByCal := map[string][]ian.Todo{}
+
+		for _, todo := range todos {
+			cal := todo.Path.Calendar()
+			todosByCal[cal] = append(todosByCal[cal], todo)
+			if _, ok := eventsByCal[cal]; !ok {
+				eventsByCal[cal] = []ian.Event{}
+			}
+		}
+
 		for cal, events := range eventsByCal {
+			todos := todosByCal[cal]
       if cal == "." {
         cal = "main"
       }
@@ -131,7 +151,9 @@ func migrateExportCmdRun(cmd *cobra.Command, args []string) {
 				"-", "",
 				"/", "-",
 			).Replace(cal) + ".ics"
-			ics := ian.ToIcal(events, "")
+			ics := ian.ToIcal(events, todos, cal)
+			instance.Config.SetIcalCalendarColor(ics, cal)
+			instance.SetIcalCalendarExtra(ics, cal)
       out, err := ian.SerializeIcal(ics)
       if err != nil {
         log.Fatal(err)
@@ -141,11 +163,11 @@ func migrateExportCmdRun(cmd *cobra.Command, args []string) {
 			}
 		}
 	} else {
-    ics := ian.ToIcal(events, "")
+    ics := ian.ToIcal(events, todos, "")
     out, err := ian.SerializeIcal(ics)
     if err != nil {
       log.Fatal(err)
     }
-		fmt.Print(out)
+		fmt.Print(out.String())
 	}
 }
@@ -41,10 +41,15 @@ func GetRoot() string {
 	return dir
 }
 
+// checkCollision warns about (or with 'no-collision', prevents) events that would be busy at the same time as props.
+// Only busy events collide; transparent or cancelled events never do.
 func checkCollision(events *[]ian.Event, props ian.EventProperties) {
+	if !props.IsBusy() {
+		return
+	}
 	if !ignoreCollisionWarnings || noCollision {
 		collidingEvents := ian.FilterEvents(events, func(e *ian.Event) bool {
-			return e.Props.Uid != props.Uid && !slices.Contains(collisionExceptions, e.Path.Calendar()) && ian.DoPeriodsMeet(props.GetTimeRange(), e.Props.GetTimeRange())
+			return !e.Replaced && e.Props.Uid != props.Uid && e.Props.IsBusy() && !ian.IsCalendarInAny(e.Path.Calendar(), collisionExceptions) && ian.DoPeriodsMeet(props.GetTimeRange(), e.Props.GetTimeRange())
 		})
 
 		if !ignoreCollisionWarnings {
@@ -77,6 +82,7 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&ian.Verbose, "verbose", "v", false, "Enable verbose mode. More information is given.")
 	rootCmd.PersistentFlags().BoolVar(&noCollision, "no-collision", false, "Prevent events from being created or edited to collide with another event.")
 	rootCmd.PersistentFlags().StringSliceVar(&collisionExceptions, "collision-exceptions", []string{}, "Mark a list of `calendars` as exceptions for collisions. When a calendar is listed, collision warnings will not be shown, and when combined with 'no-collision' a collision for an event within a calendar specified here will pass.")
+	rootCmd.PersistentFlags().Bool("category-colors", false, "Color events by their (first) category instead of their calendar. Category colors are configured in the calendar configuration.")
 	rootCmd.PersistentFlags().BoolVar(&ignoreCollisionWarnings, "no-collision-warnings", false, "Hides the warnings shown when an event will collide with an existing event.")
 	viper.BindPFlag("root", rootCmd.PersistentFlags().Lookup("root"))
 	viper.BindPFlag("timezone", rootCmd.PersistentFlags().Lookup("timezone"))
@@ -84,6 +90,7 @@ func init() {
 	viper.BindPFlag("no-collision", rootCmd.PersistentFlags().Lookup("no-collision"))
 	viper.BindPFlag("collision-exceptions", rootCmd.PersistentFlags().Lookup("collision-exceptions"))
 	viper.BindPFlag("no-collision-warnings", rootCmd.PersistentFlags().Lookup("no-collision-warnings"))
+	viper.BindPFlag("category-colors", rootCmd.PersistentFlags().Lookup("category-colors"))
 
 	rootCmd.Flags().Int("first-weekday", 2, "Specify the first day of the week by index (1 = Sunday, 2 = Monday, ... 7 = Saturday).")
 	rootCmd.Flags().BoolP("weeks", "w", false, "Show week numbers.")
@@ -165,6 +172,7 @@ func rootCmdRun(cmd *cobra.Command, args []string) {
 		if err != nil {
 			log.Fatal(err)
 		}
+		events = ian.FilterOccurring(events)
 	}
 
 	var leftSide, rightSide []string
@@ -195,23 +203,15 @@ func rootCmdRun(cmd *cobra.Command, args []string) {
 				case len(eventsInDay) == 1:
 					return ian.GetEventRgbAnsiSeq(eventsInDay[0], instance, false), false
 				case len(eventsInDay) > 1:
-					sameCalendar := true
-					var calendar string
-					for _, event := range eventsInDay {
-						if calendar == "" {
-							calendar = event.Path.Calendar()
-							continue
-						}
-
-						if calendar != event.Path.Calendar() {
-							sameCalendar = false
-							break
-						}
+					label, ok := ian.GetCommonColorLabel(eventsInDay)
+					if !ok {
+						return "\033[4m", false
 					}
-					if sameCalendar {
+					if label == ian.GetEventColorLabel(eventsInDay[0]) {
 						return ian.GetEventRgbAnsiSeq(eventsInDay[0], instance, false) + "\033[4m", false
 					}
-					return "\033[4m", false
+					// The events are in different calendars nested in the same calendar.
+					return ian.GetCalendarRgbAnsiSeq(label, instance, false) + "\033[4m", false
 				default:
 					return "\033[2m", false
 				}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/truecrunchyfrog/ian"
+)
+
+func init() {
+	rsvpCmd.Flags().String("email", "", "The `address` of the attendee to reply as. Defaults to the 'email' preference.")
+	viper.BindPFlag("email", rsvpCmd.Flags().Lookup("email"))
+
+	eventPropsCmd.AddCommand(rsvpCmd)
+}
+
+var rsvpCmd = &cobra.Command{
+	Use:       "rsvp event accepted|declined|tentative",
+	Aliases:   []string{"reply", "respond"},
+	Short:     "Reply to an event invitation",
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: []string{"accepted", "declined", "tentative"},
+	Run:       rsvpCmdRun,
+}
+
+func rsvpCmdRun(cmd *cobra.Command, args []string) {
+	var status ian.ParticipationStatus
+	switch strings.ToLower(args[1]) {
+	case "accepted", "accept", "yes":
+		status = ian.PartStatAccepted
+	case "declined", "decline", "no":
+		status = ian.PartStatDeclined
+	case "tentative", "maybe":
+		status = ian.PartStatTentative
+	default:
+		log.Fatalf("invalid reply '%s'. expected 'accepted', 'declined' or 'tentative'.\n", args[1])
+	}
+
+	email := viper.GetString("email")
+	if email == "" {
+		log.Fatal("no address to reply as. set the 'email' preference or use '--email'.")
+	}
+
+	instance, err := ian.CreateInstance(GetRoot())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	events, _, err := instance.ReadEvents(ian.TimeRange{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	event, err := ian.FindEvent(&events, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if event.Type == ian.EventTypeRecurrence && !event.Parent.Constant {
+		// Replying to a generated occurrence only replies to that occurrence, by overriding it.
+		override, err := instance.NewOverride(event)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("note: '%s' is a recurrence of '%s', and is overridden by '%s'.\n", event.Path, event.Parent.Path, override.Path)
+		*event = override
+	}
+	if event.Constant {
+		log.Fatalf("'%s' is in a read-only source, where the reply cannot be saved. reply with the calendar that invited you instead.\n", event.Path)
+	}
+
+	attendee := event.Props.GetAttendee(email)
+	if attendee == nil {
+		log.Fatalf("'%s' is not an attendee of '%s'.\n", email, event.Path)
+	}
+
+	attendee.Status = status
+	attendee.Rsvp = false
+	event.Props.Modified = time.Now().In(ian.GetTimeZone())
+
+	err = instance.Sync(func() error {
+		return event.Write(instance)
+	}, ian.SyncEvent{
+		Type:    ian.SyncEventUpdate,
+		Files:   []string{event.Path.Filepath(instance)},
+		Message: fmt.Sprintf("ian: rsvp '%s' to event '%s'", strings.ToLower(string(status)), event.Path),
+	}, false, nil)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("'%s' has been %s\n", event.Path, strings.ToLower(string(status)))
+}
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"slices"
 
 	"github.com/spf13/cobra"
@@ -36,27 +39,60 @@ func sourcesCmdRun(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 
+  // Interrupting (Ctrl-C) cancels the updates, like the automatic ones.
+  ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+  defer stop()
+
   if clean {
     log.Println("cleaning...")
-    if err := instance.CleanSources(); err != nil {
+    if err := instance.CleanSources(ctx); err != nil {
       log.Fatal(err)
     }
   }
 
-  for _, name := range updateSources {
-    if _, ok := instance.Config.Sources[name]; !ok {
-      log.Fatalf("no such source: '%s'\n", name)
+  if updateAll {
+    updateSources = nil
+    for name := range instance.Config.Sources {
+      updateSources = append(updateSources, name)
+    }
+  }
+  if len(updateSources) != 0 {
+    log.Printf("updating %d source(s)...\n", len(updateSources))
+    // Failed updates are recorded in the journal, and shown below.
+    if err := instance.UpdateSourcesByName(ctx, updateSources); err != nil {
+      log.Fatal(err)
     }
   }
 
-	for name, source := range instance.Config.Sources {
-		fmt.Printf("'%s' (%s): \033[2m%s\033[22m\n", name, source.Type, source.Source)
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		if updateAll || slices.Contains(updateSources, name) {
-			fmt.Printf("(updating '%s'...)\n", name)
-      if err := source.ImportAndUse(instance, name); err != nil {
-        log.Fatal(err)
-      }
+	for name, source := range instance.Config.Sources {
+		sourceType := source.Type
+		if source.Writable {
+			sourceType += ", writable"
+		}
+		if source.Auth != "" {
+			sourceType += ", " + source.Auth + " auth"
+		}
+		fmt.Printf("'%s' (%s): \033[2m%s\033[22m\n", name, sourceType, source.Source)
+		if journalSource := journal.Sources[name]; journalSource.Error != "" {
+			fmt.Printf("last update failed %s: %s\n", journalSource.ErrorTime.Format(ian.DefaultTimeLayout), journalSource.Error)
+			if !journalSource.LastUpdate.IsZero() {
+				fmt.Printf("cached since %s\n", journalSource.LastUpdate.Format(ian.DefaultTimeLayout))
+			}
+		}
+		if dropped := journal.Sources[name].Dropped; len(dropped) != 0 {
+			rules := []string{}
+			for rule := range dropped {
+				rules = append(rules, rule)
+			}
+			slices.Sort(rules)
+			for _, rule := range rules {
+				fmt.Printf("\033[2mdropped %d event(s) by %s\033[22m\n", dropped[rule], rule)
+			}
 		}
 
     fmt.Println()
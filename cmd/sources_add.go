@@ -10,17 +10,32 @@ import (
 )
 
 var lifetime string
+var writable bool
+var timeout string
+var auth string
+var username string
+var header string
+var passwordCommand string
+var passwordEnv string
 
 func init() {
 	sourcesAddCmd.Flags().StringVar(&lifetime, "lifetime", "", "Set the duration (e.g. '1h30m') between updates of this source.")
+	sourcesAddCmd.Flags().StringVar(&timeout, "timeout", "", "Set how long (e.g. '10s') an update of this source may take.")
+	sourcesAddCmd.Flags().StringVar(&auth, "auth", "", "Authenticate requests to the source with 'basic', 'bearer' or 'header' auth.")
+	sourcesAddCmd.Flags().StringVar(&username, "username", "", "Set the username for basic auth.")
+	sourcesAddCmd.Flags().StringVar(&header, "header", "", "Set the header (e.g. 'X-API-Key') for header auth.")
+	sourcesAddCmd.Flags().StringVar(&passwordCommand, "password-command", "", "Set the shell `command` (e.g. 'pass show cal/work') that prints the password or token for auth.")
+	sourcesAddCmd.Flags().StringVar(&passwordEnv, "password-env", "", "Set the environment `variable` with the password or token for auth.")
+	sourcesAddCmd.MarkFlagsMutuallyExclusive("password-command", "password-env")
+	sourcesAddCmd.Flags().BoolVar(&writable, "writable", false, "Write changes to the source's events back to it. Only for 'caldav' sources.")
 
 	sourcesCmd.AddCommand(sourcesAddCmd)
 }
 
 var sourcesAddCmd = &cobra.Command{
 	Use:   "add <name> <type> <source>",
-	Short: "Configure a source. The configuration will be formatted.",
-	Long:  "If you have comments in your configuration, configure the source manually instead of using this command.",
+	Short: "Configure a source",
+	Long:  "The source is added to the end of the configuration, and the rest of it is kept as is.",
 	Args:  cobra.ExactArgs(3),
 	Run:   sourcesAddCmdRun,
 }
@@ -29,31 +44,43 @@ func sourcesAddCmdRun(cmd *cobra.Command, args []string) {
 	if lifetime != "" {
     if _, err := time.ParseDuration(lifetime); err != nil {
       log.Fatal(err)
+    }
+	}
+	if timeout != "" {
+    if d, err := time.ParseDuration(timeout); err != nil {
+      log.Fatal(err)
+    } else if d <= 0 {
+      log.Fatal("timeout must be positive")
     }
 	}
 
   name, _type, source := args[0], args[1], args[2]
 
-	config, err := ian.ReadConfig(GetRoot())
-	if err != nil {
-		log.Fatal(err)
-	}
-
-  if config.Sources == nil {
-    config.Sources = map[string]ian.CalendarSource{}
+  if err := ian.ValidateSourceName(name); err != nil {
+    log.Fatal(err)
   }
 
-  if _, ok := config.Sources[name]; ok {
-    log.Fatalf("a source with the name '%s' is already configured.\n", name)
+  if writable && _type != "caldav" {
+    log.Fatal("only caldav sources can be writable")
   }
 
-  config.Sources[name] = ian.CalendarSource{
-  	Source:   source,
-  	Type:     _type,
-  	Lifetime: lifetime,
+  newSource := ian.CalendarSource{
+  	Source:          source,
+  	Type:            _type,
+  	Writable:        writable,
+  	Lifetime:        lifetime,
+  	Timeout:         timeout,
+  	Auth:            auth,
+  	Username:        username,
+  	Header:          header,
+  	PasswordCommand: passwordCommand,
+  	PasswordEnv:     passwordEnv,
+  }
+  if err := newSource.ValidateAuth(); err != nil {
+    log.Fatal(err)
   }
 
-  if err := ian.WriteConfig(GetRoot(), config); err != nil {
+  if err := ian.AddSourceConfig(GetRoot(), name, newSource); err != nil {
     log.Fatal(err)
   }
 
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/truecrunchyfrog/ian"
+)
+
+func init() {
+	sourcesCmd.AddCommand(sourcesRemoveCmd)
+}
+
+var sourcesRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm", "delete"},
+	Short:   "Remove a source, and its cache",
+	Args:    cobra.ExactArgs(1),
+	Run:     sourcesRemoveCmdRun,
+}
+
+func sourcesRemoveCmdRun(cmd *cobra.Command, args []string) {
+	instance := readSourcesInstance()
+	name := args[0]
+
+	if err := instance.RemoveSource(name); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("source '%s' removed\n", name)
+}
+
+// readSourcesInstance returns the instance without updating its sources, to manage them.
+func readSourcesInstance() *ian.Instance {
+	config, err := ian.ReadConfig(GetRoot())
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &ian.Instance{
+		Root:   GetRoot(),
+		Config: config,
+	}
+}
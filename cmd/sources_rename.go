@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	sourcesCmd.AddCommand(sourcesRenameCmd)
+}
+
+var sourcesRenameCmd = &cobra.Command{
+	Use:     "rename <name> <new name>",
+	Aliases: []string{"mv"},
+	Short:   "Rename a source",
+	Long:    "The cache of the source is kept, and the configurations of its calendars are renamed too.",
+	Args:    cobra.ExactArgs(2),
+	Run:     sourcesRenameCmdRun,
+}
+
+func sourcesRenameCmdRun(cmd *cobra.Command, args []string) {
+	instance := readSourcesInstance()
+	name, newName := args[0], args[1]
+
+	if err := instance.RenameSource(name, newName); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("source '%s' renamed to '%s'\n", name, newName)
+}
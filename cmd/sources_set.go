@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/truecrunchyfrog/ian"
+)
+
+func init() {
+	sourcesCmd.AddCommand(sourcesSetCmd)
+}
+
+var sourcesSetCmd = &cobra.Command{
+	Use:   "set <name> <attribute> <value>",
+	Short: "Set an attribute of a source",
+	Long:  "The attribute is one of: " + strings.Join(ian.SourceConfigKeys, ", ") + ". An empty value (\"\") removes the attribute.\nFilters and rewrites are configured manually.",
+	Args:  cobra.ExactArgs(3),
+	Run:   sourcesSetCmdRun,
+}
+
+func sourcesSetCmdRun(cmd *cobra.Command, args []string) {
+	instance := readSourcesInstance()
+	name, key, value := args[0], args[1], args[2]
+
+	if _, ok := instance.Config.Sources[name]; !ok {
+		log.Fatalf("no such source: '%s'\n", name)
+	}
+
+	if err := instance.SetSource(name, key, value); err != nil {
+		log.Fatal(err)
+	}
+
+	if value == "" {
+		fmt.Printf("'%s' of source '%s' removed\n", key, name)
+	} else {
+		fmt.Printf("'%s' of source '%s' set\n", key, name)
+	}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/truecrunchyfrog/ian"
+)
+
+func init() {
+	sourcesCmd.AddCommand(sourcesShowCmd)
+}
+
+var sourcesShowCmd = &cobra.Command{
+	Use:     "show <name>",
+	Aliases: []string{"status", "info"},
+	Short:   "Show the configuration and cache of a source",
+	Args:    cobra.ExactArgs(1),
+	Run:     sourcesShowCmdRun,
+}
+
+func sourcesShowCmdRun(cmd *cobra.Command, args []string) {
+	instance := readSourcesInstance()
+	name := args[0]
+
+	source, ok := instance.Config.Sources[name]
+	if !ok {
+		log.Fatalf("no such source: '%s'\n", name)
+	}
+
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		log.Fatal(err)
+	}
+	journalSource := journal.Sources[name]
+
+	fmt.Printf("'%s' (%s): \033[2m%s\033[22m\n", name, source.Type, source.Source)
+	if calendarName := journalSource.Calendar.Name; calendarName != "" {
+		fmt.Printf("name: %s\n", calendarName)
+	}
+	if interval := journalSource.Calendar.RefreshInterval; interval != 0 && source.Lifetime_ == 0 {
+		fmt.Printf("refreshed every %s, as suggested by the source\n", interval)
+	}
+	if source.Writable {
+		fmt.Println("writable")
+	}
+	if source.Auth != "" {
+		fmt.Printf("%s auth\n", source.Auth)
+	}
+
+	if journalSource.LastUpdate.IsZero() {
+		fmt.Println("last update: never")
+	} else {
+		fmt.Printf("last update: %s\n", journalSource.LastUpdate.Format(ian.DefaultTimeLayout))
+	}
+	if journalSource.Error != "" {
+		fmt.Printf("last error (%s): %s\n", journalSource.ErrorTime.Format(ian.DefaultTimeLayout), journalSource.Error)
+	}
+
+	events, err := instance.ReadCachedEvents()
+	if err != nil {
+		log.Fatal(err)
+	}
+	count := 0
+	var span ian.TimeRange
+	for _, event := range events {
+		if source, _ := ian.SourceOfCalendar(event.Path.Calendar()); source != name {
+			continue
+		}
+		count++
+		if span.From.IsZero() || event.Props.Start.Before(span.From) {
+			span.From = event.Props.Start
+		}
+		if event.Props.End.After(span.To) {
+			span.To = event.Props.End
+		}
+	}
+	fmt.Printf("events: %d\n", count)
+	if count != 0 {
+		fmt.Printf("from %s to %s\n", span.From.Format(ian.DefaultTimeLayout), span.To.Format(ian.DefaultTimeLayout))
+	}
+
+	rules := []string{}
+	for rule := range journalSource.Dropped {
+		rules = append(rules, rule)
+	}
+	slices.Sort(rules)
+	for _, rule := range rules {
+		fmt.Printf("dropped %d event(s) by %s\n", journalSource.Dropped[rule], rule)
+	}
+
+	size, err := instance.SourceCacheSize(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("cache size: %.1f KiB\n", float64(size)/1024)
+}
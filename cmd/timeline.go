@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"log"
-	"slices"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -12,7 +11,7 @@ import (
 
 func init() {
 	timelineCmd.Flags().BoolP("past", "p", false, "Show past events")
-	timelineCmd.Flags().StringSliceP("calendars", "c", nil, "Limit the shown events to those contained in the calendars in this `list`.")
+	timelineCmd.Flags().StringSliceP("calendars", "c", nil, "Limit the shown events to those contained in the calendars in this `list`, including their nested calendars.")
 	timelineCmd.Flags().Bool("no-legend", false, "Do not show the calendar legend that shows what colors belong to what calendar.")
 
 	rootCmd.AddCommand(timelineCmd)
@@ -22,7 +21,7 @@ var timelineCmd = &cobra.Command{
 	Use:     "timeline [from [to]]",
 	Aliases: []string{"time", "t", "tl", "events", "evs"},
 	Short:   "View events in a timeline",
-	Long:    "View events in a beautiful linear timeline. Without any arguments, 'from' is now, and 'to' is 5 years ahead in time. Works good with 'more' and 'less'.",
+	Long:    "View events in a beautiful linear timeline. Without any arguments, 'from' is now, and 'to' is 5 years ahead in time (of 'from'). Works good with 'more' and 'less'.",
 	Args:    cobra.RangeArgs(0, 2),
 	Run:     timelineCmdRun,
 }
@@ -57,14 +56,31 @@ func timelineCmdRun(cmd *cobra.Command, args []string) {
 		if err != nil {
 			log.Fatal(err)
 		}
+	} else {
+		timeRange.To = timeRange.From.AddDate(5, 0, 0)
 	}
 
-	events, unsatisfiedRecurrences, _ := instance.ReadEvents(timeRange)
+	it, err := instance.IterateEvents(timeRange)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	if cals, _ := cmd.Flags().GetStringSlice("calendars"); len(cals) != 0 {
-		events = ian.FilterEvents(&events, func(e *ian.Event) bool {
-			return slices.Contains(cals, e.Path.Calendar())
-		})
+	cals, _ := cmd.Flags().GetStringSlice("calendars")
+	events := []ian.Event{}
+	for it.Next() {
+		if event := it.Event(); len(cals) == 0 || ian.IsCalendarInAny(event.Path.Calendar(), cals) {
+			events = append(events, event)
+		}
+	}
+
+	// Only hinted at when 'to' is not specified.
+	var unsatisfiedRecurrences []*ian.Event
+	if len(args) < 2 {
+		for _, event := range it.Unsatisfied() {
+			if len(cals) == 0 || ian.IsCalendarInAny(event.Path.Calendar(), cals) {
+				unsatisfiedRecurrences = append(unsatisfiedRecurrences, event)
+			}
+		}
 	}
 
 	if len(events) == 0 {
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(todoCmd)
+}
+
+var todoCmd = &cobra.Command{
+	Use:     "todo",
+	Aliases: []string{"todos", "td"},
+	Short:   "Manage to-dos (tasks).",
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/truecrunchyfrog/ian"
+)
+
+func init() {
+	todoAddCmd.Flags().StringP("calendar", "c", "", "Specify calendar to place the to-do in.")
+	todoAddCmd.Flags().StringP("description", "D", "", "Detailed to-do description.")
+	todoAddCmd.Flags().StringP("location", "l", "", "Where the to-do is taking place (e.g. address).")
+	todoAddCmd.Flags().StringP("url", "u", "", "A URL relevant to the to-do.")
+	todoAddCmd.Flags().StringP("start", "s", "", "When work on the to-do begins.")
+	todoAddCmd.Flags().IntP("priority", "p", 0, "Priority from 1 (highest) to 9 (lowest). 0 is undefined.")
+
+	todoCmd.AddCommand(todoAddCmd)
+}
+
+var todoAddCmd = &cobra.Command{
+	Use:     "add summary [due]",
+	Aliases: []string{"a", "create", "new", "n"},
+	Short:   "Create a new to-do",
+	Args:    cobra.RangeArgs(1, 2),
+	Run:     todoAddCmdRun,
+}
+
+func todoAddCmdRun(cmd *cobra.Command, args []string) {
+	var props ian.TodoProperties
+	var err error
+
+	props.Summary = args[0]
+
+	if len(args) >= 2 {
+		props.Due, err = ian.ParseDateTime(args[1], ian.GetTimeZone())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if start, _ := cmd.Flags().GetString("start"); start != "" {
+		props.Start, err = ian.ParseDateTime(start, ian.GetTimeZone())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	props.Description, _ = cmd.Flags().GetString("description")
+	props.Location, _ = cmd.Flags().GetString("location")
+	props.Url, _ = cmd.Flags().GetString("url")
+	props.Priority, _ = cmd.Flags().GetInt("priority")
+	props.Status = ian.TodoStatusNeedsAction
+
+	props.Uid = ian.GenerateUid()
+
+	now := time.Now().In(ian.GetTimeZone())
+	props.Created = now
+	props.Modified = now
+
+	if err := props.Validate(); err != nil {
+		log.Fatal("invalid to-do: ", err)
+	}
+
+	instance, err := ian.CreateInstance(GetRoot())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	calendar, _ := cmd.Flags().GetString("calendar")
+	todo, err := instance.NewTodo(props, calendar)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = instance.Sync(func() error {
+		return todo.Write(instance)
+	}, ian.SyncEvent{
+		Type:    ian.SyncEventCreate,
+		Files:   []string{todo.Path.Filepath(instance)},
+		Message: fmt.Sprintf("ian: create to-do '%s'", todo.Path.String()),
+	}, false, nil)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(props.Summary)
+	if !props.Due.IsZero() {
+		fmt.Printf("\ndue %s\n", props.Due.Format(ian.DefaultTimeLayout))
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/truecrunchyfrog/ian"
+)
+
+func init() {
+	todoCmd.AddCommand(todoDoneCmd)
+}
+
+var todoDoneCmd = &cobra.Command{
+	Use:     "done todo...",
+	Aliases: []string{"d", "complete", "finish"},
+	Short:   "Mark to-do(s) as completed",
+	Run:     todoDoneCmdRun,
+}
+
+func todoDoneCmdRun(cmd *cobra.Command, args []string) {
+	instance, err := ian.CreateInstance(GetRoot())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	todos, err := instance.ReadTodos()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			args = append(args, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(args) == 0 {
+		log.Fatal("no to-do to complete")
+	}
+
+	doneTodos := []*ian.Todo{}
+	files := []string{}
+
+	syncMsg := "ian: complete "
+	if len(args) > 1 {
+		syncMsg += fmt.Sprintf("%d to-dos; ", len(args))
+	} else {
+		syncMsg += "to-do: "
+	}
+
+	now := time.Now().In(ian.GetTimeZone())
+
+	for i, arg := range args {
+		todo, err := ian.GetTodo(&todos, arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if todo.Constant {
+			log.Fatalf("'%s' is a constant to-do and cannot be modified.\n", todo.Path)
+		}
+		if todo.Props.IsDone() {
+			log.Printf("note: '%s' is already done.\n", todo.Path)
+		}
+
+		todo.Props.Complete(now)
+
+		files = append(files, todo.Path.Filepath(instance))
+		if i != 0 {
+			syncMsg += ", "
+		}
+		syncMsg += "'" + todo.Path.String() + "'"
+
+		doneTodos = append(doneTodos, todo)
+	}
+
+	err = instance.Sync(func() error {
+		for _, todo := range doneTodos {
+			if err := todo.Write(instance); err != nil {
+				return err
+			}
+			fmt.Printf("'%s' is done\n", todo.Path)
+		}
+		return nil
+	}, ian.SyncEvent{
+		Type:    ian.SyncEventUpdate,
+		Files:   files,
+		Message: syncMsg,
+	}, false, nil)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
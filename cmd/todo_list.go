@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/truecrunchyfrog/ian"
+)
+
+func init() {
+	todoListCmd.Flags().BoolP("all", "a", false, "Also show completed and cancelled to-dos.")
+	todoListCmd.Flags().StringSliceP("calendars", "c", nil, "Limit the shown to-dos to those contained in the calendars in this `list`, including their nested calendars.")
+	todoListCmd.Flags().BoolP("paths", "p", false, "Only print the paths of the to-dos.")
+
+	todoCmd.AddCommand(todoListCmd)
+}
+
+var todoListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"l", "ls"},
+	Short:   "List to-dos",
+	Args:    cobra.NoArgs,
+	Run:     todoListCmdRun,
+}
+
+func todoListCmdRun(cmd *cobra.Command, args []string) {
+	instance, err := ian.CreateInstance(GetRoot())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	todos, err := instance.ReadTodos()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	all, _ := cmd.Flags().GetBool("all")
+	cals, _ := cmd.Flags().GetStringSlice("calendars")
+
+	todos = ian.FilterTodos(&todos, func(t *ian.Todo) bool {
+		if !all && t.Props.IsDone() {
+			return false
+		}
+		return len(cals) == 0 || ian.IsCalendarInAny(t.Path.Calendar(), cals)
+	})
+
+	slices.SortFunc(todos, ian.CompareTodos)
+
+	if onlyPaths, _ := cmd.Flags().GetBool("paths"); onlyPaths {
+		for _, todo := range todos {
+			fmt.Println(todo.Path)
+		}
+		return
+	}
+
+	if len(todos) == 0 {
+		fmt.Println("\033[2;3mNothing to do.\033[0m")
+		return
+	}
+
+	now := time.Now().In(ian.GetTimeZone())
+
+	for _, todo := range todos {
+		fmt.Println(displayTodo(instance, &todo, now))
+	}
+}
+
+func displayTodo(instance *ian.Instance, todo *ian.Todo, now time.Time) string {
+	check := "[ ]"
+	if todo.Props.IsDone() {
+		check = "[x]"
+	}
+
+	var due string
+	if !todo.Props.Due.IsZero() {
+		due = " \033[2mdue " + todo.Props.Due.In(ian.GetTimeZone()).Format(ian.DefaultTimeLayout)
+		if !todo.Props.IsDone() && todo.Props.Due.Before(now) {
+			due += " \033[22;31m(overdue)"
+		}
+		due += "\033[0m"
+	}
+
+	rgb := (&ian.CalendarConfig{}).GetColor()
+	if conf, err := instance.Config.GetContainerConfig(todo.Path.Calendar()); err == nil {
+		rgb = conf.GetColor()
+	}
+
+	return fmt.Sprintf("%s%s\033[0m %s%s \033[2m%s\033[0m", ian.RgbToAnsiSeq(rgb, false), check, todo.Props.Summary, due, todo.Path)
+}
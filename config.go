@@ -6,6 +6,7 @@ import (
 	"image/color"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -15,8 +16,10 @@ const ConfigFilename string = ".config.toml"
 
 type Config struct {
 	Calendars map[string]CalendarConfig
-	Sources   map[string]CalendarSource
-	Hooks     map[string]Hook
+	// Categories configure event categories, which are used instead of calendars when coloring by category.
+	Categories map[string]CalendarConfig
+	Sources    map[string]CalendarSource
+	Hooks      map[string]Hook
 }
 
 type CalendarConfig struct {
@@ -58,12 +61,23 @@ func ReadConfig(root string) (Config, error) {
 		return Config{}, err
 	}
 
+	return parseConfig(string(buf))
+}
+
+// parseConfig decodes and validates a configuration.
+func parseConfig(s string) (Config, error) {
 	var config Config
-	if _, err := toml.Decode(string(buf), &config); err != nil {
+	if _, err := toml.Decode(s, &config); err != nil {
 		return Config{}, err
 	}
 
 	for name, source := range config.Sources {
+		if source.Writable && source.Type != "caldav" {
+			return Config{}, errors.New("in configuration source '" + name + "': only caldav sources can be writable.")
+		}
+		if err := source.ValidateAuth(); err != nil {
+			return Config{}, errors.New("in configuration source '" + name + "': " + err.Error() + ".")
+		}
 		if source.Lifetime != "" {
 			d, err := time.ParseDuration(source.Lifetime)
 			if err != nil {
@@ -76,6 +90,42 @@ func ReadConfig(root string) (Config, error) {
 			source.Lifetime_ = d
 			config.Sources[name] = source
 		}
+		if source.Timeout != "" {
+			d, err := time.ParseDuration(source.Timeout)
+			if err != nil {
+				return Config{}, err
+			}
+			if d <= 0 {
+				return Config{}, errors.New("in configuration source '" + name + "': timeout must be positive.")
+			}
+
+			source.Timeout_ = d
+			config.Sources[name] = source
+		}
+		if source.Shift != "" {
+			d, err := time.ParseDuration(source.Shift)
+			if err != nil {
+				return Config{}, err
+			}
+
+			source.Shift_ = d
+			config.Sources[name] = source
+		}
+		if source.Cutoff != "" {
+			d, err := time.ParseDuration(source.Cutoff)
+			if err != nil {
+				return Config{}, err
+			}
+			if d <= 0 {
+				return Config{}, errors.New("in configuration source '" + name + "': cutoff must be positive.")
+			}
+
+			source.Cutoff_ = d
+			config.Sources[name] = source
+		}
+		if err := source.ValidateRules(); err != nil {
+			return Config{}, errors.New("in configuration source '" + name + "': " + err.Error() + ".")
+		}
 	}
 
 	for name, listener := range config.Hooks {
@@ -109,13 +159,41 @@ func WriteConfig(root string, config Config) error {
 	return nil
 }
 
+// GetContainerConfig returns the configuration of the calendar.
+// Nested calendars inherit what they do not configure themselves from the calendars they are nested in (see ParentCalendar).
 func (conf *Config) GetContainerConfig(container string) (*CalendarConfig, error) {
-	for name, cal := range conf.Calendars {
-		if name == container {
-			return &cal, nil
+	var found *CalendarConfig
+	for calendar, ok := container, true; ok; calendar, ok = ParentCalendar(calendar) {
+		cal, exists := conf.Calendars[calendar]
+		if !exists {
+			continue
+		}
+		if found == nil {
+			found = &cal
+		} else {
+			found.inherit(cal)
+		}
+	}
+	if found == nil {
+		return nil, errors.New("calendar config for '" + container + "' does not exist")
+	}
+	return found, nil
+}
+
+// inherit sets what the configuration does not set from parent.
+func (conf *CalendarConfig) inherit(parent CalendarConfig) {
+	if r, g, b, _ := conf.Color.RGBA(); r+g+b == 0 {
+		conf.Color = parent.Color
+	}
+}
+
+func (conf *Config) GetCategoryConfig(category string) (*CalendarConfig, error) {
+	for name, cat := range conf.Categories {
+		if strings.EqualFold(name, category) {
+			return &cat, nil
 		}
 	}
-	return nil, errors.New("calendar config for '" + container + "' does not exist")
+	return nil, errors.New("category config for '" + category + "' does not exist")
 }
 
 func (conf *CalendarConfig) GetColor() color.RGBA {
@@ -0,0 +1,318 @@
+package ian
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// The configuration is edited as text (instead of being decoded and encoded again), so that its comments and formatting are kept.
+
+// SourceConfigKeys are the attributes of sources that can be set with SetSourceConfig.
+// The other attributes (like filters) are edited in the configuration file.
+var SourceConfigKeys = []string{"source", "type", "writable", "lifetime", "timeout", "auth", "username", "header", "passwordcommand", "passwordenv", "shift", "cutoff"}
+
+var bareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// formatTableHeader returns the header of the table, e.g. '[calendars.".joe"]'.
+func formatTableHeader(keys ...string) string {
+	quoted := []string{}
+	for _, key := range keys {
+		if bareKeyRegexp.MatchString(key) {
+			quoted = append(quoted, key)
+		} else {
+			quoted = append(quoted, strconv.Quote(key))
+		}
+	}
+	return "[" + strings.Join(quoted, ".") + "]"
+}
+
+// parseTableHeader returns the keys of the line if it is the header of a table (not an array of tables).
+func parseTableHeader(line string) ([]string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || strings.HasPrefix(line, "[[") {
+		return nil, false
+	}
+	rest := line[1:]
+
+	keys := []string{}
+	for {
+		rest = strings.TrimLeft(rest, " \t")
+		var key string
+		switch {
+		case strings.HasPrefix(rest, `"`):
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(rest) {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(rest[:end+1])
+			if err != nil {
+				return nil, false
+			}
+			key, rest = unquoted, rest[end+1:]
+		case strings.HasPrefix(rest, "'"):
+			end := strings.Index(rest[1:], "'")
+			if end < 0 {
+				return nil, false
+			}
+			key, rest = rest[1:end+1], rest[end+2:]
+		default:
+			end := strings.IndexFunc(rest, func(r rune) bool {
+				return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+			})
+			if end <= 0 {
+				return nil, false
+			}
+			key, rest = rest[:end], rest[end:]
+		}
+		keys = append(keys, key)
+
+		rest = strings.TrimLeft(rest, " \t")
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "]"):
+			rest = strings.TrimSpace(rest[1:])
+			if rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, false
+			}
+			return keys, true
+		default:
+			return nil, false
+		}
+	}
+}
+
+// isHeader returns true if the line starts a table or an array of tables.
+func isHeader(line string) bool {
+	if _, ok := parseTableHeader(line); ok {
+		return true
+	}
+	return strings.HasPrefix(strings.TrimSpace(line), "[[")
+}
+
+// findTable returns the range of lines of the table, from its header to its last attribute.
+// The comments and empty lines after it are left out, since they belong to the next table.
+func findTable(lines []string, keys ...string) (int, int, bool) {
+	for start, line := range lines {
+		if headerKeys, ok := parseTableHeader(line); !ok || !slices.Equal(headerKeys, keys) {
+			continue
+		}
+		end := start + 1
+		for end < len(lines) && !isHeader(lines[end]) {
+			end++
+		}
+		for end > start+1 {
+			trimmed := strings.TrimSpace(lines[end-1])
+			if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				break
+			}
+			end--
+		}
+		return start, end, true
+	}
+	return 0, 0, false
+}
+
+// formatConfigValue returns the TOML of the value.
+func formatConfigValue(value any) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(map[string]any{"v": value}); err != nil {
+		return "", err
+	}
+	_, encoded, _ := strings.Cut(strings.TrimSpace(buf.String()), "= ")
+	return encoded, nil
+}
+
+// editConfig edits the lines of the configuration file, and writes it if the edited configuration is valid.
+func editConfig(root string, edit func(lines []string) ([]string, error)) error {
+	buf, err := os.ReadFile(getConfigPath(root))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	lines, err := edit(strings.Split(string(buf), "\n"))
+	if err != nil {
+		return err
+	}
+	edited := strings.Join(lines, "\n")
+
+	if _, err := parseConfig(edited); err != nil {
+		return fmt.Errorf("the edited configuration is invalid, so it was not written: %s", err)
+	}
+	return os.WriteFile(getConfigPath(root), []byte(edited), 0644)
+}
+
+// sourceTableError is returned when a source cannot be edited as text.
+func sourceTableError(name string) error {
+	return fmt.Errorf("source '%s' is not configured as a '%s' table. edit the configuration manually", name, formatTableHeader("sources", name))
+}
+
+// AddSourceConfig adds the source to the end of the configuration file.
+func AddSourceConfig(root, name string, source CalendarSource) error {
+	return editConfig(root, func(lines []string) ([]string, error) {
+		if _, _, ok := findTable(lines, "sources", name); ok {
+			return nil, fmt.Errorf("a source with the name '%s' is already configured", name)
+		}
+
+		table := []string{formatTableHeader("sources", name)}
+		for _, attr := range []struct {
+			key   string
+			value any
+		}{
+			{"source", source.Source},
+			{"type", source.Type},
+			{"writable", source.Writable},
+			{"lifetime", source.Lifetime},
+			{"timeout", source.Timeout},
+			{"auth", source.Auth},
+			{"username", source.Username},
+			{"header", source.Header},
+			{"passwordcommand", source.PasswordCommand},
+			{"passwordenv", source.PasswordEnv},
+			{"shift", source.Shift},
+			{"cutoff", source.Cutoff},
+		} {
+			if attr.value == "" || attr.value == false {
+				continue
+			}
+			value, err := formatConfigValue(attr.value)
+			if err != nil {
+				return nil, err
+			}
+			table = append(table, "  "+attr.key+" = "+value)
+		}
+
+		for len(lines) != 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		if len(lines) != 0 {
+			lines = append(lines, "")
+		}
+		return append(append(lines, table...), ""), nil
+	})
+}
+
+// SetSourceConfig sets the attribute (one of SourceConfigKeys) of the source. An empty value removes the attribute.
+func SetSourceConfig(root, name, key, value string) error {
+	if !slices.Contains(SourceConfigKeys, key) {
+		return fmt.Errorf("unknown source attribute '%s' (must be one of %s)", key, strings.Join(SourceConfigKeys, ", "))
+	}
+	if value == "" && (key == "source" || key == "type") {
+		return fmt.Errorf("the %s of a source cannot be removed", key)
+	}
+
+	var line string
+	if value != "" {
+		var typed any = value
+		if key == "writable" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("writable must be true or false")
+			}
+			typed = b
+		}
+		encoded, err := formatConfigValue(typed)
+		if err != nil {
+			return err
+		}
+		line = key + " = " + encoded
+	}
+
+	return editConfig(root, func(lines []string) ([]string, error) {
+		start, end, ok := findTable(lines, "sources", name)
+		if !ok {
+			return nil, sourceTableError(name)
+		}
+
+		indent := "  "
+		for i := start + 1; i < end; i++ {
+			attrKey, _, ok := strings.Cut(lines[i], "=")
+			trimmedKey := strings.Trim(strings.TrimSpace(attrKey), `"'`)
+			// Skips comments and the lines of multiline values.
+			if !ok || !bareKeyRegexp.MatchString(trimmedKey) {
+				continue
+			}
+			indent = attrKey[:len(attrKey)-len(strings.TrimLeft(attrKey, " \t"))]
+			if !strings.EqualFold(trimmedKey, key) {
+				continue
+			}
+			if line == "" {
+				return slices.Delete(lines, i, i+1), nil
+			}
+			lines[i] = indent + line
+			return lines, nil
+		}
+
+		if line == "" {
+			return lines, nil
+		}
+		return slices.Insert(lines, end, indent+line), nil
+	})
+}
+
+// removeSourceConfig removes the table of the source from the configuration file.
+func removeSourceConfig(root, name string) error {
+	return editConfig(root, func(lines []string) ([]string, error) {
+		start, end, ok := findTable(lines, "sources", name)
+		if !ok {
+			return nil, sourceTableError(name)
+		}
+		// Along with the empty line before it.
+		if start > 0 && strings.TrimSpace(lines[start-1]) == "" {
+			start--
+		}
+		return slices.Delete(lines, start, end), nil
+	})
+}
+
+// renameSourceConfig renames the table of the source, and the tables of its calendars in calendars.
+func renameSourceConfig(root, name, newName string) error {
+	return editConfig(root, func(lines []string) ([]string, error) {
+		start, _, ok := findTable(lines, "sources", name)
+		if !ok {
+			return nil, sourceTableError(name)
+		}
+		if _, _, ok := findTable(lines, "sources", newName); ok {
+			return nil, fmt.Errorf("a source with the name '%s' is already configured", newName)
+		}
+		lines[start] = formatTableHeader("sources", newName)
+
+		for i, line := range lines {
+			keys, ok := parseTableHeader(line)
+			if !ok || len(keys) != 2 || keys[0] != "calendars" {
+				continue
+			}
+			if keys[1] == "."+name {
+				lines[i] = formatTableHeader("calendars", "."+newName)
+			} else if calendar, ok := strings.CutPrefix(keys[1], "."+name+SourceCalendarSeparator); ok {
+				lines[i] = formatTableHeader("calendars", "."+newName+SourceCalendarSeparator+calendar)
+			}
+		}
+		return lines, nil
+	})
+}
+
+// ValidateSourceName returns an error if the name cannot be used for a source.
+func ValidateSourceName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.Contains(name, SourceCalendarSeparator) || strings.Contains(name, CalendarSeparator) {
+		return errors.New("bad source name '" + name + "'")
+	}
+	if _, err := NewEventPath("."+name, "x"); err != nil {
+		return errors.New("bad source name '" + name + "'")
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package ian
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditSourceConfig(t *testing.T) {
+	root := t.TempDir()
+	feed := filepath.Join(t.TempDir(), "schedule.ics")
+	if err := os.WriteFile(feed, []byte(localSourceCalendar()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := `# my calendars
+
+[calendars.".school"]
+  color = { r = 1, g = 2, b = 3 }
+
+[sources.school]
+  source = "` + feed + `" # the schedule
+  type = "file"
+
+# hooks
+[hooks.git]
+  postcommand = "true"
+`
+	if err := os.WriteFile(getConfigPath(root), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	readConfigFile := func() string {
+		buf, err := os.ReadFile(getConfigPath(root))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf)
+	}
+	newInstance := func() *Instance {
+		config, err := ReadConfig(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Instance{Root: root, Config: config}
+	}
+
+	instance := newInstance()
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := instance.SetSource("school", "lifetime", "1h"); err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.SetSource("school", "lifetime", "3h"); err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.SetSource("school", "lifetime", "never"); err == nil {
+		t.Error("expected an invalid lifetime to be rejected")
+	}
+	if err := instance.SetSource("school", "include", "x"); err == nil {
+		t.Error("expected an unknown attribute to be rejected")
+	}
+	if !strings.Contains(readConfigFile(), "  type = \"file\"\n  lifetime = \"3h\"\n\n# hooks") {
+		t.Errorf("expected the lifetime to be set once, got:\n%s", readConfigFile())
+	}
+
+	if err := instance.RenameSource("school", "uni"); err != nil {
+		t.Fatal(err)
+	}
+	instance = newInstance()
+	if _, ok := instance.Config.Sources["uni"]; !ok {
+		t.Fatal("expected the source to be renamed")
+	}
+	if _, err := instance.Config.GetContainerConfig(".uni"); err != nil {
+		t.Error("expected the calendar configuration to be renamed")
+	}
+	if !strings.Contains(readConfigFile(), `# the schedule`) {
+		t.Error("expected the comments to be kept")
+	}
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := journal.Sources["uni"]; !ok || len(journal.Sources) != 1 {
+		t.Errorf("expected the journal entry to be renamed, got %v", journal.Sources)
+	}
+	if _, err := os.Stat(filepath.Join(instance.getCacheDir(), "uni")); err != nil {
+		t.Error("expected the cache to be moved")
+	}
+
+	if err := AddSourceConfig(root, "team", CalendarSource{Source: "/srv/team", Type: "native", Lifetime: "1h"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSourceConfig(root, "team", CalendarSource{Source: "/srv/team", Type: "native"}); err == nil {
+		t.Error("expected a duplicate source to be rejected")
+	}
+
+	if err := instance.RemoveSource("uni"); err != nil {
+		t.Fatal(err)
+	}
+	instance = newInstance()
+	if _, ok := instance.Config.Sources["uni"]; ok || len(instance.Config.Sources) != 1 {
+		t.Errorf("expected only the added source to be left, got %v", instance.Config.Sources)
+	}
+	if _, err := os.Stat(filepath.Join(instance.getCacheDir(), "uni")); !os.IsNotExist(err) {
+		t.Error("expected the cache to be removed")
+	}
+	if journal, err = instance.ReadCacheJournal(); err != nil || len(journal.Sources) != 0 {
+		t.Errorf("expected the journal entry to be removed, got %v", journal.Sources)
+	}
+	if s := readConfigFile(); !strings.HasPrefix(s, "# my calendars\n") || !strings.Contains(s, "# hooks\n[hooks.git]") {
+		t.Errorf("expected the rest of the configuration to be kept, got:\n%s", s)
+	}
+}
+
+func TestParseTableHeader(t *testing.T) {
+	for line, expected := range map[string][]string{
+		`[sources.joe]`:                 {"sources", "joe"},
+		`  [ sources . "joe" ] # joe`:   {"sources", "joe"},
+		`[calendars.".team:work"]`:      {"calendars", ".team:work"},
+		`[calendars.'.team']`:           {"calendars", ".team"},
+		`[[hooks]]`:                     nil,
+		`["a", "b"]`:                    nil,
+		`  source = "[sources.joe]"`:    nil,
+		`[sources.joe] source = "file"`: nil,
+	} {
+		keys, ok := parseTableHeader(line)
+		if ok != (expected != nil) || strings.Join(keys, "|") != strings.Join(expected, "|") {
+			t.Errorf("%s: expected %v, got %v", line, expected, keys)
+		}
+	}
+}
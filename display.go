@@ -6,6 +6,8 @@ import (
 	"slices"
 	"strings"
 	"time"
+
+	"github.com/spf13/viper"
 )
 
 func DisplayCalendar(
@@ -95,10 +97,13 @@ func RgbToAnsiSeq(rgb color.RGBA, background bool) string {
 }
 
 // GetEventRgbAnsiSeq is a helper function to quickly get the color of an event based on its container.
+// If the 'category-colors' preference is set, the color is instead based on the event's first category, when it has one.
+// The event's own color is preferred over both.
 func GetEventRgbAnsiSeq(event *Event, instance *Instance, background bool) string {
-	calendar := event.Path.Calendar()
 	var rgb color.RGBA
-	if conf, err := instance.Config.GetContainerConfig(calendar); err == nil {
+	if eventColor, ok := ParseIcalColor(event.Props.Color); ok {
+		rgb = eventColor
+	} else if conf, err := getEventColorConfig(event, instance); err == nil {
 		rgb = conf.GetColor()
 	} else {
 		rgb = (&CalendarConfig{}).GetColor()
@@ -106,6 +111,54 @@ func GetEventRgbAnsiSeq(event *Event, instance *Instance, background bool) strin
 	return RgbToAnsiSeq(rgb, background)
 }
 
+// GetEventColorLabel returns what the color of an event represents; its calendar or category.
+func GetEventColorLabel(event *Event) string {
+	if viper.GetBool("category-colors") && len(event.Props.Categories) != 0 {
+		return event.Props.Categories[0]
+	}
+	return event.Path.Calendar()
+}
+
+// GetCommonColorLabel returns the color label that the events have in common.
+// Events in different calendars have the calendar that their calendars are nested in in common (see CommonCalendar).
+func GetCommonColorLabel(events []*Event) (string, bool) {
+	if len(events) == 0 {
+		return "", false
+	}
+	common := GetEventColorLabel(events[0])
+	isCalendar := common == events[0].Path.Calendar()
+	for _, event := range events[1:] {
+		label := GetEventColorLabel(event)
+		if label == common {
+			continue
+		}
+		if !isCalendar || label != event.Path.Calendar() {
+			return "", false
+		}
+		var ok bool
+		if common, ok = CommonCalendar(common, label); !ok {
+			return "", false
+		}
+	}
+	return common, true
+}
+
+// GetCalendarRgbAnsiSeq returns the ANSI sequence of the calendar's color.
+func GetCalendarRgbAnsiSeq(calendar string, instance *Instance, background bool) string {
+	conf, err := instance.Config.GetContainerConfig(calendar)
+	if err != nil {
+		conf = &CalendarConfig{}
+	}
+	return RgbToAnsiSeq(conf.GetColor(), background)
+}
+
+func getEventColorConfig(event *Event, instance *Instance) (*CalendarConfig, error) {
+	if viper.GetBool("category-colors") && len(event.Props.Categories) != 0 {
+		return instance.Config.GetCategoryConfig(event.Props.Categories[0])
+	}
+	return instance.Config.GetContainerConfig(event.Path.Calendar())
+}
+
 type eventEntry struct {
 	event    *Event
 	parent   *eventEntry
@@ -201,7 +254,7 @@ func displayEntry(instance *Instance, entry *eventEntry, showDates bool, lastSho
       output += pipes
     }
 
-		output += GetEventRgbAnsiSeq(entry.event, instance, false) + prefix + entry.event.Props.Summary + suffix + "\033[0m"
+		output += GetEventRgbAnsiSeq(entry.event, instance, false) + prefix + statusFormat(&entry.event.Props) + entry.event.Props.Summary + suffix + "\033[0m"
 	}
 	for _, child := range entry.children {
 		// Children
@@ -235,6 +288,19 @@ func displayEntry(instance *Instance, entry *eventEntry, showDates bool, lastSho
 	return output
 }
 
+// statusFormat returns an ANSI format for the summary of an event based on its status.
+// Cancelled events are struck through, and tentative events are italic.
+func statusFormat(props *EventProperties) string {
+	switch props.Status {
+	case EventStatusCancelled:
+		return "\033[9m"
+	case EventStatusTentative:
+		return "\033[3m"
+	default:
+		return ""
+	}
+}
+
 func displayPipes(instance *Instance, entry *eventEntry) string {
 	pipes := []string{}
 	parent := entry.parent
@@ -247,6 +313,8 @@ func displayPipes(instance *Instance, entry *eventEntry) string {
 }
 
 func DisplayTimeline(instance *Instance, events []Event, showDates bool, lastShownDate time.Time, location *time.Location) string {
+	events = FilterOccurring(events)
+
 	// Sort the events first:
 
 	slices.SortFunc(events, func(e1 Event, e2 Event) int {
@@ -295,15 +363,54 @@ func DisplayUnsatisfiedRecurrences(instance *Instance, unsatisfiedRecurrences []
 	return output
 }
 
+// DisplayCalendarLegend shows the color of each label of the events.
+// Nested calendars are shown under the calendar they are nested in, by the rest of their names.
 func DisplayCalendarLegend(instance *Instance, events []Event) string {
-	var output string
-	mentionedCals := []string{}
+	mentionedLabels := []string{}
+	labelEvents := map[string]*Event{}
+
+	for i := range events {
+		label := GetEventColorLabel(&events[i])
+		if _, ok := labelEvents[label]; !ok {
+			mentionedLabels = append(mentionedLabels, label)
+			labelEvents[label] = &events[i]
+		}
+	}
 
-	for _, event := range events {
-		cal := event.Path.Calendar()
-		if !slices.Contains(mentionedCals, cal) {
-			output += fmt.Sprintf(GetEventRgbAnsiSeq(&event, instance, false)+"▆ %s\033[0m\n", cal)
-			mentionedCals = append(mentionedCals, cal)
+	isCalendar := func(label string) bool {
+		event, ok := labelEvents[label]
+		return ok && event.Path.Calendar() == label
+	}
+	parents := map[string]string{}
+	children := map[string][]string{}
+	for _, label := range mentionedLabels {
+		if !isCalendar(label) {
+			continue
+		}
+		for parent, ok := ParentCalendar(label); ok; parent, ok = ParentCalendar(parent) {
+			if isCalendar(parent) {
+				parents[label] = parent
+				children[parent] = append(children[parent], label)
+				break
+			}
+		}
+	}
+
+	var output string
+	var displayLabel func(label string, depth int)
+	displayLabel = func(label string, depth int) {
+		name := label
+		if parent, ok := parents[label]; ok {
+			name = label[len(parent)+1:]
+		}
+		output += fmt.Sprintf(strings.Repeat("  ", depth)+GetEventRgbAnsiSeq(labelEvents[label], instance, false)+"▆ %s\033[0m\n", name)
+		for _, child := range children[label] {
+			displayLabel(child, depth+1)
+		}
+	}
+	for _, label := range mentionedLabels {
+		if _, ok := parents[label]; !ok {
+			displayLabel(label, 0)
 		}
 	}
 
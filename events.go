@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -38,16 +39,14 @@ type eventPath struct {
 }
 
 // NewEventPath safely constructs an EventPath from a calendar and name, which can be easily used for determining paths and filenames.
-// name may be modified.
+// The calendar may be nested (see CalendarSeparator). name may be modified.
 func NewEventPath(calendar, name string) (EventPath, error) {
-	illegalChars := "\n/" + string(filepath.Separator)
-
-	if calendar == "" || strings.ContainsAny(calendar, illegalChars+" ") {
+	if !isValidCalendarName(calendar) {
 		return nil, fmt.Errorf("bad calendar name '%s'", calendar)
 	}
 
 	name = strings.TrimSpace(name)
-	if name == "" || strings.ContainsAny(name, illegalChars) {
+	if name == "" || strings.ContainsAny(name, "\n/"+string(filepath.Separator)) {
 		return nil, fmt.Errorf("bad event name '%s'", name)
 	}
 
@@ -59,16 +58,17 @@ func NewEventPath(calendar, name string) (EventPath, error) {
 
 // NewFreeEventPath is like NewEventPath, but ensures that the filename is available, possibly by changing it.
 func NewFreeEventPath(instance *Instance, calendar, name string) (EventPath, error) {
-	safeName, err := instance.getAvailableFilename(filepath.Join(instance.Root, calendar), name)
+	safeName, err := instance.getAvailableFilename(instance.getCalendarDir(calendar), name)
 	if err != nil {
 		return nil, err
 	}
 	return NewEventPath(calendar, safeName)
 }
 
+// ParseEventPath parses a path like 'work/oncall/Page someone', where the last part is the name of the event.
 func ParseEventPath(input string) (EventPath, error) {
 	cal, name := path.Split(input)
-	return NewEventPath(cal, name)
+	return NewEventPath(strings.TrimSuffix(cal, "/"), name)
 }
 
 func (p *eventPath) Calendar() string {
@@ -84,7 +84,7 @@ func (p *eventPath) String() string {
 }
 
 func (p *eventPath) Filepath(instance *Instance) string {
-	return filepath.Join(instance.Root, p.calendar, p.name)
+	return filepath.Join(instance.getCalendarDir(p.calendar), p.name)
 }
 
 type Event struct {
@@ -96,13 +96,36 @@ type Event struct {
 	Constant bool
 	// Parent is the parent event if this event is generated from a recurrence rule. Otherwise nil.
 	Parent *Event
+	// Replaced is true if the event is a recurring event whose own (first) occurrence is replaced by an override.
+	// It is kept so that the recurring event can be found (e.g. to be edited or deleted), but it does not occur itself.
+	Replaced bool
+}
+
+// FilterOccurring returns the events that occur, i.e. all except replaced recurring events (see Event.Replaced).
+func FilterOccurring(events []Event) []Event {
+	return FilterEvents(&events, func(e *Event) bool {
+		return !e.Replaced
+	})
 }
 
 // Write writes the event to the appropriate location in 'instance'.
+// Events in a source calendar are written to the source (see Instance.WriteSourceEvent).
 func (event *Event) Write(instance *Instance) error {
+	if source, ok := SourceOfCalendar(event.Path.Calendar()); ok {
+		return instance.WriteSourceEvent(source, event.Props)
+	}
 	return event.Props.Write(event.Path.Filepath(instance))
 }
 
+// Delete deletes the event from 'instance'.
+// Events in a source calendar are deleted from the source (see Instance.DeleteSourceEvent).
+func (event *Event) Delete(instance *Instance) error {
+	if source, ok := SourceOfCalendar(event.Path.Calendar()); ok {
+		return instance.DeleteSourceEvent(source, event.Props)
+	}
+	return os.Remove(event.Path.Filepath(instance))
+}
+
 func (event *Event) String() string {
 	return event.Path.String()
 }
@@ -117,6 +140,192 @@ func (rec *Recurrence) IsThereRecurrence() bool {
 	return rec.RRule != "" || rec.RDate != ""
 }
 
+type EventStatus string
+
+const (
+	EventStatusTentative EventStatus = "TENTATIVE"
+	EventStatusConfirmed EventStatus = "CONFIRMED"
+	EventStatusCancelled EventStatus = "CANCELLED"
+)
+
+// Transparency is whether an event blocks time (opaque) or not (transparent).
+type Transparency string
+
+const (
+	TransparencyOpaque      Transparency = "OPAQUE"
+	TransparencyTransparent Transparency = "TRANSPARENT"
+)
+
+type AlarmAction string
+
+const (
+	AlarmActionDisplay AlarmAction = "DISPLAY"
+	AlarmActionAudio   AlarmAction = "AUDIO"
+	AlarmActionEmail   AlarmAction = "EMAIL"
+)
+
+// Alarm is a reminder for an event (VALARM).
+type Alarm struct {
+	Action AlarmAction
+
+	// Trigger is when the alarm goes off relative to the start of the event, or the end if TriggerFromEnd is true.
+	// A negative trigger is before.
+	Trigger        time.Duration
+	TriggerFromEnd bool
+	// TriggerAt is an absolute time for the alarm to go off. If it is set, Trigger and TriggerFromEnd are ignored.
+	TriggerAt time.Time
+
+	// Description is the text shown by a DISPLAY alarm, or the body of an EMAIL alarm.
+	Description string
+	// Summary is the subject of an EMAIL alarm.
+	Summary string
+	// Attendees are who an EMAIL alarm is sent to.
+	Attendees []Attendee
+
+	// Repeat is the number of additional times the alarm goes off, every RepeatInterval.
+	Repeat         int
+	RepeatInterval time.Duration
+
+	// Extra are the iCalendar properties of the alarm that are not modelled by ian.
+	Extra []ExtraProperty
+}
+
+// Time returns when the alarm first goes off for the event with props.
+func (alarm *Alarm) Time(props *EventProperties) time.Time {
+	switch {
+	case !alarm.TriggerAt.IsZero():
+		return alarm.TriggerAt
+	case alarm.TriggerFromEnd:
+		return props.End.Add(alarm.Trigger)
+	default:
+		return props.Start.Add(alarm.Trigger)
+	}
+}
+
+func (alarm Alarm) String() string {
+	var when string
+	if !alarm.TriggerAt.IsZero() {
+		when = "at " + alarm.TriggerAt.Format(DefaultTimeLayout)
+	} else {
+		relativeTo := "start"
+		if alarm.TriggerFromEnd {
+			relativeTo = "end"
+		}
+		switch {
+		case alarm.Trigger < 0:
+			when = DurationToString(-alarm.Trigger) + " before " + relativeTo
+		case alarm.Trigger > 0:
+			when = DurationToString(alarm.Trigger) + " after " + relativeTo
+		default:
+			when = "at " + relativeTo
+		}
+	}
+
+	output := fmt.Sprintf("%s (%s)", when, strings.ToLower(string(alarm.Action)))
+	if alarm.Repeat > 0 {
+		output += fmt.Sprintf(", repeated %d times every %s", alarm.Repeat, DurationToString(alarm.RepeatInterval))
+	}
+	return output
+}
+
+type ParticipationStatus string
+
+const (
+	PartStatNeedsAction ParticipationStatus = "NEEDS-ACTION"
+	PartStatAccepted    ParticipationStatus = "ACCEPTED"
+	PartStatDeclined    ParticipationStatus = "DECLINED"
+	PartStatTentative   ParticipationStatus = "TENTATIVE"
+	PartStatDelegated   ParticipationStatus = "DELEGATED"
+)
+
+// ExtraProperty is an iCalendar property that ian does not model, kept so that it survives a round trip through ian.
+type ExtraProperty struct {
+	Name string
+	// Value is the raw (still escaped) value.
+	Value  string
+	Params map[string][]string
+}
+
+// Organizer is the calendar user that organizes an event (ORGANIZER).
+type Organizer struct {
+	// Address is the calendar user address, like 'mailto:joe@example.com'.
+	Address string
+	// Name is the common name (CN).
+	Name string
+	// Params are the parameters that ian does not model, like SENT-BY.
+	Params map[string][]string
+}
+
+// Attendee is a participant of an event (ATTENDEE).
+type Attendee struct {
+	// Address is the calendar user address, like 'mailto:joe@example.com'.
+	Address string
+	// Name is the common name (CN).
+	Name string
+	// Role is e.g. 'CHAIR', 'REQ-PARTICIPANT' or 'OPT-PARTICIPANT'.
+	Role   string
+	Status ParticipationStatus
+	// Rsvp is true if a reply is expected from the attendee.
+	Rsvp bool
+	// Params are the parameters that ian does not model, like CUTYPE and DELEGATED-TO.
+	Params map[string][]string
+}
+
+func (attendee Attendee) String() string {
+	var output string
+	if attendee.Name != "" {
+		output = fmt.Sprintf("%s <%s>", attendee.Name, strings.TrimPrefix(attendee.Address, "mailto:"))
+	} else {
+		output = strings.TrimPrefix(attendee.Address, "mailto:")
+	}
+
+	details := []string{}
+	if attendee.Role != "" {
+		details = append(details, strings.ToLower(attendee.Role))
+	}
+	if attendee.Status != "" {
+		details = append(details, strings.ToLower(string(attendee.Status)))
+	}
+	if attendee.Rsvp {
+		details = append(details, "rsvp")
+	}
+	if len(details) != 0 {
+		output += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return output
+}
+
+// ParseCalendarAddress parses an address like 'Joe <joe@example.com>', 'joe@example.com' or 'mailto:joe@example.com'
+// into a calendar user address ('mailto:joe@example.com') and a possibly empty name.
+func ParseCalendarAddress(input string) (address string, name string, err error) {
+	address = strings.TrimSpace(input)
+
+	if i := strings.LastIndex(address, "<"); i != -1 && strings.HasSuffix(address, ">") {
+		name = strings.Trim(strings.TrimSpace(address[:i]), `"`)
+		address = address[i+1 : len(address)-1]
+	}
+
+	if strings.HasPrefix(strings.ToLower(address), "mailto:") {
+		address = address[len("mailto:"):]
+	}
+
+	if at := strings.Index(address, "@"); at <= 0 || at == len(address)-1 || strings.ContainsAny(address, " <>") {
+		return "", "", fmt.Errorf("bad address '%s'", input)
+	}
+
+	return "mailto:" + address, name, nil
+}
+
+// IsSameCalendarAddress compares two calendar user addresses, ignoring casing and the 'mailto:' scheme.
+func IsSameCalendarAddress(a1, a2 string) bool {
+	normalize := func(a string) string {
+		a = strings.ToLower(strings.TrimSpace(a))
+		return strings.TrimPrefix(a, "mailto:")
+	}
+	return normalize(a1) == normalize(a2)
+}
+
 type EventProperties struct {
 	Uid string
 
@@ -129,8 +338,37 @@ type EventProperties struct {
 	Start time.Time
 	// End is a non-inclusive datetime representing when the event ends.
 	End time.Time
+	// TimeZone is the IANA name of the time zone that the event is planned in (e.g. 'Europe/Stockholm').
+	// Recurrences follow its offset changes. If empty, the global time zone is used (see GetTimeZone).
+	TimeZone string
 
 	Recurrence Recurrence
+	// RecurrenceId is only set on overrides. It is the original start of the occurrence that the override replaces,
+	// in the recurring event with the same UID in the same calendar.
+	RecurrenceId time.Time
+	// RelatedTo is the UID of the event that this event is related to, e.g. the series that a split recurring event continues.
+	RelatedTo string
+
+	// Status is empty, or one of the EventStatus constants.
+	Status EventStatus
+	// Transparency is empty (which is opaque), or one of the Transparency constants.
+	Transparency Transparency
+
+	Categories []string
+
+	// Color is a CSS3 color name (COLOR, see ParseIcalColor) that the event is shown in, instead of the color of its calendar or category.
+	Color string
+
+	Alarms []Alarm
+
+	Organizer Organizer
+	Attendees []Attendee
+
+	// Extra are the iCalendar properties of the event that are not modelled by ian, like X- properties, CLASS and GEO.
+	Extra []ExtraProperty
+	// ExtraParams are the parameters of the modelled properties that ian does not model (like the LANGUAGE of the
+	// DESCRIPTION), by property name. Those of the organizer and attendees are kept with them instead.
+	ExtraParams map[string]map[string][]string
 
 	Created  time.Time
 	Modified time.Time
@@ -151,8 +389,19 @@ func (props *EventProperties) Write(file string) error {
 	return nil
 }
 
+// GetTimeZoneLocation returns the location of the event's time zone, or the global time zone if it has none.
+func (props *EventProperties) GetTimeZoneLocation() *time.Location {
+	if props.TimeZone != "" {
+		if loc, err := time.LoadLocation(props.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return GetTimeZone()
+}
+
 func (props *EventProperties) GetRruleSet() (rrule.Set, error) {
 	set := rrule.Set{}
+	loc := props.GetTimeZoneLocation()
 
 	if s := props.Recurrence.RRule; s != "" {
 		rr, err := rrule.StrToRRule(s)
@@ -160,11 +409,11 @@ func (props *EventProperties) GetRruleSet() (rrule.Set, error) {
 			return rrule.Set{}, fmt.Errorf("RRULE parse failed: %s", err)
 		}
 		set.RRule(rr)
-		set.DTStart(props.Start)
+		set.DTStart(props.Start.In(loc))
 	}
 
 	if s := props.Recurrence.RDate; s != "" {
-		rd, err := rrule.StrToDates(s)
+		rd, err := rrule.StrToDatesInLoc(s, loc)
 		if err != nil {
 			return rrule.Set{}, fmt.Errorf("RDATE parse failed: %s", err)
 		}
@@ -172,7 +421,7 @@ func (props *EventProperties) GetRruleSet() (rrule.Set, error) {
 	}
 
 	if s := props.Recurrence.ExDate; s != "" {
-		xd, err := rrule.StrToDates(s)
+		xd, err := rrule.StrToDatesInLoc(s, loc)
 		if err != nil {
 			return rrule.Set{}, fmt.Errorf("EXDATE parse failed: %s", err)
 		}
@@ -182,6 +431,114 @@ func (props *EventProperties) GetRruleSet() (rrule.Set, error) {
 	return set, nil
 }
 
+// SplitRecurrence splits the recurring event at the occurrence starting at t.
+// The event is modified to end just before t, and the returned event continues the recurrence from t.
+// The returned event gets a new UID, and is related to the original event through RelatedTo.
+func (props *EventProperties) SplitRecurrence(t time.Time) (EventProperties, error) {
+	if !props.Recurrence.IsThereRecurrence() || props.IsOverride() {
+		return EventProperties{}, errors.New("not a recurring event")
+	}
+	if !t.After(props.Start) {
+		return EventProperties{}, errors.New("cannot split a recurring event at its first occurrence")
+	}
+
+	rruleSet, err := props.GetRruleSet()
+	if err != nil {
+		return EventProperties{}, err
+	}
+	if !rruleSet.After(t, true).Equal(t) {
+		return EventProperties{}, fmt.Errorf("there is no occurrence at %s", t.Format(DefaultTimeLayout))
+	}
+
+	next := *props
+	next.Categories = slices.Clone(props.Categories)
+	next.Alarms = slices.Clone(props.Alarms)
+	next.Attendees = slices.Clone(props.Attendees)
+
+	if s := props.Recurrence.RRule; s != "" {
+		opt, err := rrule.StrToROption(s)
+		if err != nil {
+			return EventProperties{}, fmt.Errorf("RRULE parse failed: %s", err)
+		}
+
+		if opt.Count != 0 {
+			// The continuation only gets the occurrences that are left.
+			opt.Dtstart = props.Start.In(props.GetTimeZoneLocation())
+			rr, err := rrule.NewRRule(*opt)
+			if err != nil {
+				return EventProperties{}, err
+			}
+			before := len(slices.DeleteFunc(rr.Between(props.Start, t, true), func(o time.Time) bool {
+				return !o.Before(t)
+			}))
+			nextOpt := *opt
+			nextOpt.Dtstart = time.Time{}
+			nextOpt.Count -= before
+			if nextOpt.Count > 0 {
+				next.Recurrence.RRule = nextOpt.RRuleString()
+			} else {
+				next.Recurrence.RRule = ""
+			}
+		}
+
+		opt.Dtstart = time.Time{}
+		opt.Count = 0
+		opt.Until = t.Add(-time.Second).In(time.UTC)
+		props.Recurrence.RRule = opt.RRuleString()
+	}
+
+	for _, dates := range []struct{ before, after *string }{
+		{&props.Recurrence.RDate, &next.Recurrence.RDate},
+		{&props.Recurrence.ExDate, &next.Recurrence.ExDate},
+	} {
+		if *dates.before == "" {
+			continue
+		}
+		ts, err := rrule.StrToDatesInLoc(*dates.before, props.GetTimeZoneLocation())
+		if err != nil {
+			return EventProperties{}, err
+		}
+		var before, after []string
+		for _, d := range ts {
+			if d.Before(t) {
+				before = append(before, d.In(time.UTC).Format("20060102T150405Z"))
+			} else {
+				after = append(after, d.In(time.UTC).Format("20060102T150405Z"))
+			}
+		}
+		*dates.before = strings.Join(before, ",")
+		*dates.after = strings.Join(after, ",")
+	}
+
+	now := time.Now().In(GetTimeZone())
+
+	next.Uid = GenerateUid()
+	next.RelatedTo = props.Uid
+	next.Start = t
+	next.End = t.Add(props.End.Sub(props.Start))
+	next.Created = now
+	next.Modified = now
+
+	props.Modified = now
+
+	return next, nil
+}
+
+// ExcludeOccurrence adds an exception date for the occurrence starting at t, so that it is no longer generated.
+func (props *EventProperties) ExcludeOccurrence(t time.Time) {
+	date := t.In(time.UTC).Format("20060102T150405Z")
+	if props.Recurrence.ExDate == "" {
+		props.Recurrence.ExDate = date
+	} else {
+		props.Recurrence.ExDate += "," + date
+	}
+}
+
+// IsOverride returns true if the event replaces a single occurrence of a recurring event.
+func (props *EventProperties) IsOverride() bool {
+	return !props.RecurrenceId.IsZero()
+}
+
 func (props *EventProperties) IsAllDay() bool {
 	h, m, s := props.Start.Clock()
 	h2, m2, s2 := props.End.Clock()
@@ -208,6 +565,40 @@ func (p *EventProperties) Validate() error {
 		return errors.New("start cannot be chronologically after end")
 	case p.Created.After(p.Modified):
 		return errors.New("created cannot be chronologically after modified")
+	case !p.RecurrenceId.IsZero() && p.Recurrence.IsThereRecurrence():
+		return errors.New("an override cannot recur itself")
+	}
+
+	if p.TimeZone != "" {
+		if _, err := time.LoadLocation(p.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone '%s': %s", p.TimeZone, err)
+		}
+	}
+
+	switch p.Status {
+	case "", EventStatusTentative, EventStatusConfirmed, EventStatusCancelled:
+	default:
+		return fmt.Errorf("invalid status '%s'", p.Status)
+	}
+
+	switch p.Transparency {
+	case "", TransparencyOpaque, TransparencyTransparent:
+	default:
+		return fmt.Errorf("invalid transparency '%s'", p.Transparency)
+	}
+
+	for _, alarm := range p.Alarms {
+		switch {
+		// Actions other than DISPLAY, AUDIO and EMAIL (e.g. of other applications) are kept as they are.
+		case alarm.Action == "":
+			return errors.New("alarm action cannot be empty")
+		case alarm.Action == AlarmActionEmail && len(alarm.Attendees) == 0:
+			return errors.New("email alarm must have an attendee")
+		case alarm.Repeat < 0:
+			return errors.New("alarm repeat cannot be negative")
+		case alarm.Repeat > 0 && alarm.RepeatInterval <= 0:
+			return errors.New("repeating alarm must have a positive repeat interval")
+		}
 	}
 
 	return nil
@@ -221,6 +612,31 @@ func (props *EventProperties) FormatName() string {
 	).Replace(props.Summary)
 }
 
+// IsBusy returns true if the event blocks its time, i.e. it is opaque and not cancelled.
+func (props *EventProperties) IsBusy() bool {
+	return props.Transparency != TransparencyTransparent && props.Status != EventStatusCancelled
+}
+
+// HasCategory returns true if the event is in the category, ignoring casing.
+func (props *EventProperties) HasCategory(category string) bool {
+	for _, c := range props.Categories {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAttendee returns the attendee with the calendar user address, or nil if there is none.
+func (props *EventProperties) GetAttendee(address string) *Attendee {
+	for i := range props.Attendees {
+		if IsSameCalendarAddress(props.Attendees[i].Address, address) {
+			return &props.Attendees[i]
+		}
+	}
+	return nil
+}
+
 // TODO change path to type EventPath?
 func GetEvent(events *[]Event, path string) (*Event, error) {
 	for _, ev := range *events {
@@ -231,6 +647,29 @@ func GetEvent(events *[]Event, path string) (*Event, error) {
 	return nil, fmt.Errorf("no such event '%s'", path)
 }
 
+// FindEvent returns the event with the path or, if there is none, the event with the UID.
+// The UID of a recurring event refers to the recurring event itself, not its overrides.
+func FindEvent(events *[]Event, pathOrUid string) (*Event, error) {
+	if event, err := GetEvent(events, pathOrUid); err == nil {
+		return event, nil
+	}
+
+	var found *Event
+	for _, ev := range *events {
+		if ev.Props.Uid != pathOrUid || ev.Props.IsOverride() || ev.Parent != nil {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("the UID '%s' is ambiguous ('%s' and '%s'). use the path instead", pathOrUid, found.Path, ev.Path)
+		}
+		found = &ev
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no such event '%s'", pathOrUid)
+	}
+	return found, nil
+}
+
 func FilterEvents(events *[]Event, filter func(*Event) bool) []Event {
 	filtered := []Event{}
 
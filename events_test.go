@@ -0,0 +1,95 @@
+package ian
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitRecurrence(t *testing.T) {
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	props := EventProperties{
+		Uid:     GenerateUid(),
+		Summary: "weekly",
+		Start:   start,
+		End:     start.Add(time.Hour),
+		Recurrence: Recurrence{
+			RRule:  "FREQ=WEEKLY;COUNT=6",
+			ExDate: "20240513T090000Z,20240603T090000Z",
+		},
+	}
+
+	split := start.AddDate(0, 0, 21)
+	next, err := props.SplitRecurrence(split)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next.RelatedTo != props.Uid || next.Uid == props.Uid {
+		t.Error("split event is not related to the original by UID")
+	}
+	if !next.Start.Equal(split) || next.End.Sub(next.Start) != time.Hour {
+		t.Errorf("split event has wrong time: %s - %s", next.Start, next.End)
+	}
+	if props.Recurrence.ExDate != "20240513T090000Z" || next.Recurrence.ExDate != "20240603T090000Z" {
+		t.Errorf("exception dates were not divided: %q and %q", props.Recurrence.ExDate, next.Recurrence.ExDate)
+	}
+
+	for _, c := range []struct {
+		props EventProperties
+		want  int
+	}{
+		{props, 2},
+		{next, 2},
+	} {
+		set, err := c.props.GetRruleSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(set.All()); got != c.want {
+			t.Errorf("'%s' has %d occurrences, want %d", c.props.Recurrence.RRule, got, c.want)
+		}
+	}
+
+	if _, err := props.SplitRecurrence(start.Add(time.Minute)); err == nil {
+		t.Error("split at a time without an occurrence succeeded")
+	}
+}
+
+func TestSplitRecurrenceInTimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skip(err)
+	}
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, loc)
+
+	props := EventProperties{
+		Uid:      GenerateUid(),
+		Summary:  "weekly",
+		Start:    start,
+		End:      start.Add(time.Hour),
+		TimeZone: loc.String(),
+		Recurrence: Recurrence{
+			RRule: "FREQ=WEEKLY;COUNT=6",
+			// Floating, so in the event's time zone.
+			ExDate: "20240513T090000,20240603T090000",
+		},
+	}
+
+	next, err := props.SplitRecurrence(start.AddDate(0, 0, 21))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props.Recurrence.ExDate != "20240513T070000Z" || next.Recurrence.ExDate != "20240603T070000Z" {
+		t.Errorf("exception dates were not read in the event's time zone: %q and %q", props.Recurrence.ExDate, next.Recurrence.ExDate)
+	}
+	for _, p := range []EventProperties{props, next} {
+		set, err := p.GetRruleSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(set.All()); got != 2 {
+			t.Errorf("'%s' has %d occurrences, want 2", p.Recurrence.RRule, got)
+		}
+	}
+}
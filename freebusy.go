@@ -0,0 +1,208 @@
+package ian
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+type BusyType string
+
+const (
+	BusyTypeBusy          BusyType = "BUSY"
+	BusyTypeBusyTentative BusyType = "BUSY-TENTATIVE"
+)
+
+// BusyPeriod is a period of time that is busy, without any details about the event(s) that make it busy.
+type BusyPeriod struct {
+	TimeRange
+	Type BusyType
+}
+
+// GetBusyPeriods merges the busy events into non-overlapping busy periods confined to timeRange, sorted chronologically.
+// Tentative events are BusyTypeBusyTentative, unless they overlap with another busy event.
+// Events that are not busy (see EventProperties.IsBusy) are ignored.
+func GetBusyPeriods(events []Event, timeRange TimeRange) []BusyPeriod {
+	busy := []TimeRange{}
+	tentative := []TimeRange{}
+
+	for _, event := range events {
+		if event.Replaced || !event.Props.IsBusy() || !DoPeriodsMeet(event.Props.GetTimeRange(), timeRange) {
+			continue
+		}
+
+		period := clampPeriod(event.Props.GetTimeRange(), timeRange)
+		if event.Props.Status == EventStatusTentative {
+			tentative = append(tentative, period)
+		} else {
+			busy = append(busy, period)
+		}
+	}
+
+	busy = MergePeriods(busy)
+	tentative = SubtractPeriods(MergePeriods(tentative), busy)
+
+	periods := []BusyPeriod{}
+	for _, period := range busy {
+		periods = append(periods, BusyPeriod{period, BusyTypeBusy})
+	}
+	for _, period := range tentative {
+		periods = append(periods, BusyPeriod{period, BusyTypeBusyTentative})
+	}
+
+	slices.SortFunc(periods, func(p1, p2 BusyPeriod) int {
+		return p1.From.Compare(p2.From)
+	})
+
+	return periods
+}
+
+// GetFreePeriods returns the gaps between the busy periods within the working hours of every day in timeRange.
+// Working hours are the durations since midnight when a day begins and ends, and only weekdays in workdays are used.
+// Gaps shorter than minDuration are left out.
+func GetFreePeriods(busy []BusyPeriod, timeRange TimeRange, dayStart, dayEnd time.Duration, workdays []time.Weekday, minDuration time.Duration, location *time.Location) []TimeRange {
+	busyRanges := []TimeRange{}
+	for _, period := range busy {
+		busyRanges = append(busyRanges, period.TimeRange)
+	}
+
+	workingHours := []TimeRange{}
+
+	from := timeRange.From.In(location)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, location); day.Before(timeRange.To); day = day.AddDate(0, 0, 1) {
+		if !slices.Contains(workdays, day.Weekday()) {
+			continue
+		}
+
+		// By the clock, since a day is not 24 hours long when the offset changes.
+		clock := func(d time.Duration) time.Time {
+			return time.Date(day.Year(), day.Month(), day.Day(), int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second), 0, location)
+		}
+		hours := TimeRange{
+			From: clock(dayStart),
+			To:   clock(dayEnd),
+		}
+		if !DoPeriodsMeet(hours, timeRange) {
+			continue
+		}
+		workingHours = append(workingHours, clampPeriod(hours, timeRange))
+	}
+
+	return FilterPeriods(SubtractPeriods(workingHours, busyRanges), func(p TimeRange) bool {
+		return p.To.Sub(p.From) >= minDuration
+	})
+}
+
+// MergePeriods merges overlapping and touching periods, and returns them sorted chronologically.
+func MergePeriods(periods []TimeRange) []TimeRange {
+	sorted := slices.Clone(periods)
+	slices.SortFunc(sorted, func(p1, p2 TimeRange) int {
+		return p1.From.Compare(p2.From)
+	})
+
+	merged := []TimeRange{}
+	for _, period := range sorted {
+		if len(merged) != 0 && !period.From.After(merged[len(merged)-1].To) {
+			if last := &merged[len(merged)-1]; period.To.After(last.To) {
+				last.To = period.To
+			}
+			continue
+		}
+		merged = append(merged, period)
+	}
+
+	return merged
+}
+
+// SubtractPeriods removes the parts of periods that are covered by any of the subtrahends.
+// Both lists must be merged (see MergePeriods).
+func SubtractPeriods(periods, subtrahends []TimeRange) []TimeRange {
+	result := []TimeRange{}
+
+	for _, period := range periods {
+		remaining := []TimeRange{period}
+		for _, sub := range subtrahends {
+			next := []TimeRange{}
+			for _, r := range remaining {
+				if !DoPeriodsMeet(r, sub) {
+					next = append(next, r)
+					continue
+				}
+				if r.From.Before(sub.From) {
+					next = append(next, TimeRange{r.From, sub.From})
+				}
+				if r.To.After(sub.To) {
+					next = append(next, TimeRange{sub.To, r.To})
+				}
+			}
+			remaining = next
+		}
+		result = append(result, remaining...)
+	}
+
+	return result
+}
+
+func FilterPeriods(periods []TimeRange, filter func(TimeRange) bool) []TimeRange {
+	filtered := []TimeRange{}
+
+	for _, period := range periods {
+		if filter(period) {
+			filtered = append(filtered, period)
+		}
+	}
+
+	return filtered
+}
+
+// clampPeriod confines period to bounds.
+func clampPeriod(period, bounds TimeRange) TimeRange {
+	if period.From.Before(bounds.From) {
+		period.From = bounds.From
+	}
+	if period.To.After(bounds.To) {
+		period.To = bounds.To
+	}
+	return period
+}
+
+// ToIcalFreeBusy creates a calendar with a VFREEBUSY component, publishing the busy periods during timeRange.
+func ToIcalFreeBusy(busy []BusyPeriod, timeRange TimeRange) *ical.Calendar {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//ian//ian free/busy")
+	cal.Props.SetText(ical.PropMethod, "PUBLISH")
+
+	freeBusy := ical.NewComponent(ical.CompFreeBusy)
+	freeBusy.Props.SetText(ical.PropUID, GenerateUid())
+	freeBusy.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().In(time.UTC))
+	freeBusy.Props.SetDateTime(ical.PropDateTimeStart, timeRange.From.In(time.UTC))
+	freeBusy.Props.SetDateTime(ical.PropDateTimeEnd, timeRange.To.In(time.UTC))
+
+	for _, busyType := range []BusyType{BusyTypeBusy, BusyTypeBusyTentative} {
+		values := []string{}
+		for _, period := range busy {
+			if period.Type == busyType {
+				values = append(values, fmt.Sprintf("%s/%s",
+					period.From.In(time.UTC).Format("20060102T150405Z"),
+					period.To.In(time.UTC).Format("20060102T150405Z"),
+				))
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		prop := ical.NewProp(ical.PropFreeBusy)
+		prop.Params.Set(ical.ParamFreeBusyType, string(busyType))
+		prop.Value = strings.Join(values, ",")
+		freeBusy.Props.Add(prop)
+	}
+
+	cal.Children = append(cal.Children, freeBusy)
+
+	return cal
+}
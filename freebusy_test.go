@@ -0,0 +1,53 @@
+package ian
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetBusyPeriods(t *testing.T) {
+	base := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return base.Add(time.Duration(h) * time.Hour) }
+
+	events := []Event{
+		{Props: EventProperties{Start: at(9), End: at(11)}},
+		{Props: EventProperties{Start: at(10), End: at(12)}},
+		{Props: EventProperties{Start: at(11), End: at(14), Status: EventStatusTentative}},
+		{Props: EventProperties{Start: at(15), End: at(16), Transparency: TransparencyTransparent}},
+		{Props: EventProperties{Start: at(16), End: at(17), Status: EventStatusCancelled}},
+		{Props: EventProperties{Start: at(22), End: at(26)}},
+	}
+
+	got := GetBusyPeriods(events, TimeRange{From: base, To: at(24)})
+	want := []BusyPeriod{
+		{TimeRange{at(9), at(12)}, BusyTypeBusy},
+		{TimeRange{at(12), at(14)}, BusyTypeBusyTentative},
+		{TimeRange{at(22), at(24)}, BusyTypeBusy},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("busy periods mismatch:\n got  %v\n want %v", got, want)
+	}
+
+	free := GetFreePeriods(got, TimeRange{From: base, To: at(24)}, 8*time.Hour, 17*time.Hour, []time.Weekday{time.Monday}, 30*time.Minute, time.UTC)
+	wantFree := []TimeRange{
+		{at(8), at(9)},
+		{at(14), at(17)},
+	}
+
+	if !reflect.DeepEqual(free, wantFree) {
+		t.Fatalf("free periods mismatch:\n got  %v\n want %v", free, wantFree)
+	}
+
+	// The working hours follow the clock on the day that daylight saving time begins.
+	loc, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skip(err)
+	}
+	sunday := time.Date(2024, 3, 31, 0, 0, 0, 0, loc)
+	free = GetFreePeriods(nil, TimeRange{From: sunday, To: sunday.AddDate(0, 0, 1)}, 9*time.Hour, 17*time.Hour, []time.Weekday{time.Sunday}, 0, loc)
+	if len(free) != 1 || !free[0].From.Equal(time.Date(2024, 3, 31, 9, 0, 0, 0, loc)) || !free[0].To.Equal(time.Date(2024, 3, 31, 17, 0, 0, 0, loc)) {
+		t.Errorf("expected 09:00 to 17:00, got %v", free)
+	}
+}
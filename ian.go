@@ -71,13 +71,19 @@ func CreateFileIfMissing(name string) error {
 	}
 }
 
-// parseEventFile simply reads a file and parses it for properties.
-func parseEventFile(path string) (EventProperties, error) {
-	buf, err := os.ReadFile(path)
-	if err != nil {
-		return EventProperties{}, err
+// parseComponent reads what component a file's content describes. Files without a component are events.
+func parseComponent(buf []byte) (string, error) {
+	var header struct {
+		Component string
+	}
+	if _, err := toml.Decode(string(buf), &header); err != nil {
+		return "", err
 	}
+	return header.Component, nil
+}
 
+// parseEvent simply parses a file's content for event properties.
+func parseEvent(buf []byte) (EventProperties, error) {
 	var props EventProperties
 	if _, err := toml.Decode(string(buf), &props); err != nil {
 		return EventProperties{}, err
@@ -89,6 +95,39 @@ func parseEventFile(path string) (EventProperties, error) {
 	props.Created = props.Created.Truncate(time.Second)
 	props.Modified = props.Modified.Truncate(time.Second)
 
+	// TOML only keeps the offset, so restore the time zone.
+	props.restoreTimeZone()
+
+	return props, nil
+}
+
+// restoreTimeZone sets the location of the event's times to its TimeZone, if it has one.
+func (props *EventProperties) restoreTimeZone() {
+	if props.TimeZone == "" {
+		return
+	}
+	loc := props.GetTimeZoneLocation()
+	props.Start = props.Start.In(loc)
+	props.End = props.End.In(loc)
+	if props.IsOverride() {
+		props.RecurrenceId = props.RecurrenceId.In(loc)
+	}
+}
+
+// parseTodo simply parses a file's content for to-do properties.
+func parseTodo(buf []byte) (TodoProperties, error) {
+	var props TodoProperties
+	if _, err := toml.Decode(string(buf), &props); err != nil {
+		return TodoProperties{}, err
+	}
+
+	props.Start = props.Start.Truncate(time.Second)
+	props.Due = props.Due.Truncate(time.Second)
+	props.Completed = props.Completed.Truncate(time.Second)
+
+	props.Created = props.Created.Truncate(time.Second)
+	props.Modified = props.Modified.Truncate(time.Second)
+
 	return props, nil
 }
 
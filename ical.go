@@ -2,12 +2,98 @@ package ian
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
 	"io"
+	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/emersion/go-ical"
 )
 
+// icalEventProps are the event properties that are modelled by ian, or generated when exporting.
+// All other properties are kept as extra properties.
+var icalEventProps = []string{
+	ical.PropUID,
+	ical.PropDateTimeStamp,
+	ical.PropDateTimeStart,
+	ical.PropDateTimeEnd,
+	ical.PropDuration,
+	ical.PropSummary,
+	ical.PropDescription,
+	ical.PropLocation,
+	ical.PropURL,
+	ical.PropStatus,
+	ical.PropTransparency,
+	ical.PropRelatedTo,
+	ical.PropRecurrenceRule,
+	ical.PropRecurrenceDates,
+	ical.PropExceptionDates,
+	ical.PropRecurrenceID,
+	ical.PropCategories,
+	ical.PropOrganizer,
+	ical.PropAttendee,
+	ical.PropCreated,
+	ical.PropLastModified,
+	ical.PropColor,
+}
+
+// icalTodoProps are the to-do properties that are modelled by ian, or generated when exporting.
+var icalTodoProps = []string{
+	ical.PropUID,
+	ical.PropDateTimeStamp,
+	ical.PropSummary,
+	ical.PropDescription,
+	ical.PropLocation,
+	ical.PropURL,
+	ical.PropStatus,
+	ical.PropDateTimeStart,
+	ical.PropDue,
+	ical.PropCompleted,
+	ical.PropPercentComplete,
+	ical.PropPriority,
+	ical.PropCreated,
+	ical.PropLastModified,
+}
+
+// icalAlarmProps are the alarm properties that are modelled by ian.
+var icalAlarmProps = []string{
+	ical.PropAction,
+	ical.PropTrigger,
+	ical.PropDescription,
+	ical.PropSummary,
+	ical.PropAttendee,
+	ical.PropRepeat,
+	ical.PropDuration,
+}
+
+// icalCalendarProps are the calendar properties that are read as IcalCalendarInfo, or generated when exporting.
+var icalCalendarProps = []string{
+	ical.PropVersion,
+	ical.PropProductID,
+	ical.PropName,
+	"X-WR-CALNAME",
+	ical.PropColor,
+	ical.PropRefreshInterval,
+	IcalPropPublishedTtl,
+	IcalPropGrabTimestamp,
+}
+
+// icalKnownParams are the parameters that ian models or generates, by property.
+// All other parameters of the modelled properties are kept as extra parameters.
+var icalKnownParams = map[string][]string{
+	ical.PropDateTimeStart:   {ical.ParamValue, ical.ParamTimezoneID},
+	ical.PropDateTimeEnd:     {ical.ParamValue, ical.ParamTimezoneID},
+	ical.PropRecurrenceID:    {ical.ParamValue, ical.ParamTimezoneID},
+	ical.PropRecurrenceDates: {ical.ParamValue, ical.ParamTimezoneID},
+	ical.PropExceptionDates:  {ical.ParamValue, ical.ParamTimezoneID},
+	ical.PropOrganizer:       {ical.ParamCommonName},
+	ical.PropAttendee:        {ical.ParamCommonName, ical.ParamRole, ical.ParamParticipationStatus, ical.ParamRSVP},
+}
+
 func FromIcalEvent(icalEvent ical.Event) (EventProperties, error) {
 	start, err := icalEvent.DateTimeStart(GetTimeZone())
 	if err != nil {
@@ -18,28 +104,100 @@ func FromIcalEvent(icalEvent ical.Event) (EventProperties, error) {
 		return EventProperties{}, err
 	}
 
+	timeZone := icalTimeZone(icalEvent.Props.Get(ical.PropDateTimeStart))
+
 	if h, m, s := start.Clock(); h+m+s == 0 && start.Equal(end) {
 		// Event is an *alternatively* formatted all-day event.
 		end = start.AddDate(0, 0, 1)
 	}
 
-	var uid, summary, description, location, url, rrule, rdate, exdate string
+	var uid, summary, description, location, url, rrule, rdate, exdate, status, transparency, relatedTo, eventColor string
 
-	textPropsMap := map[*string]string{
-		&uid:         ical.PropUID,
-		&summary:     ical.PropSummary,
-		&description: ical.PropDescription,
-		&location:    ical.PropLocation,
-		&url:         ical.PropURL,
-		&rrule:       ical.PropRecurrenceRule,
-		&rdate:       ical.PropRecurrenceDates,
-		&exdate:      ical.PropExceptionDates,
+	if err := readTextProps(icalEvent.Props, map[*string]string{
+		&uid:          ical.PropUID,
+		&summary:      ical.PropSummary,
+		&description:  ical.PropDescription,
+		&location:     ical.PropLocation,
+		&status:       ical.PropStatus,
+		&transparency: ical.PropTransparency,
+		&relatedTo:    ical.PropRelatedTo,
+		&eventColor:   ical.PropColor,
+	}); err != nil {
+		return EventProperties{}, err
 	}
 
-	for dest, propName := range textPropsMap {
-		prop := icalEvent.Props.Get(propName)
-		if prop != nil {
-			*dest = prop.Value
+	readRawProps(icalEvent.Props, map[*string]string{
+		&url:   ical.PropURL,
+		&rrule: ical.PropRecurrenceRule,
+	})
+	if rdate, err = readDateListProps(icalEvent.Props, ical.PropRecurrenceDates); err != nil {
+		return EventProperties{}, err
+	}
+	if exdate, err = readDateListProps(icalEvent.Props, ical.PropExceptionDates); err != nil {
+		return EventProperties{}, err
+	}
+
+	var alarms []Alarm
+	for _, child := range icalEvent.Children {
+		if child.Name != ical.CompAlarm {
+			continue
+		}
+		alarm, err := FromIcalAlarm(child)
+		if err != nil {
+			// The rest of the event is still usable.
+			log.Printf("warning: ignored a malformed alarm of event '%s': %s\n", uid, err)
+			continue
+		}
+		alarms = append(alarms, alarm)
+	}
+
+	var categories []string
+	for _, prop := range icalEvent.Props.Values(ical.PropCategories) {
+		l, err := prop.TextList()
+		if err != nil {
+			return EventProperties{}, err
+		}
+		for _, category := range l {
+			if category = strings.TrimSpace(category); category != "" {
+				categories = append(categories, category)
+			}
+		}
+	}
+
+	var organizer Organizer
+	if prop := icalEvent.Props.Get(ical.PropOrganizer); prop != nil {
+		organizer.Address = prop.Value
+		organizer.Name = prop.Params.Get(ical.ParamCommonName)
+		organizer.Params = readExtraParams(prop, ical.PropOrganizer)
+	}
+
+	var attendees []Attendee
+	for _, prop := range icalEvent.Props.Values(ical.PropAttendee) {
+		attendees = append(attendees, fromIcalAttendee(prop))
+	}
+
+	var recurrenceId time.Time
+	if icalEvent.Props.Get(ical.PropRecurrenceID) != nil {
+		recurrenceId, err = icalEvent.Props.DateTime(ical.PropRecurrenceID, GetTimeZone())
+		if err != nil {
+			return EventProperties{}, err
+		}
+	}
+
+	extra := readExtraProps(icalEvent.Props, icalEventProps)
+
+	var extraParams map[string]map[string][]string
+	for _, name := range icalEventProps {
+		if name == ical.PropOrganizer || name == ical.PropAttendee {
+			continue
+		}
+		if prop := icalEvent.Props.Get(name); prop != nil {
+			if params := readExtraParams(prop, name); params != nil {
+				if extraParams == nil {
+					extraParams = map[string]map[string][]string{}
+				}
+				extraParams[name] = params
+			}
 		}
 	}
 
@@ -48,19 +206,44 @@ func FromIcalEvent(icalEvent ical.Event) (EventProperties, error) {
 	modified, _ := icalEvent.Props.DateTime(ical.PropLastModified, GetTimeZone())
 
 	return EventProperties{
-		Uid:         uid,
-		Summary:     summary,
-		Description: description,
-		Location:    location,
-		Url:         url,
-		Start:       start,
-		End:         end,
-		Recurrence:  Recurrence{rrule, rdate, exdate},
-		Created:     created,
-		Modified:    modified,
+		Uid:          uid,
+		Summary:      summary,
+		Description:  description,
+		Location:     location,
+		Url:          url,
+		Start:        start,
+		End:          end,
+		TimeZone:     timeZone,
+		Recurrence:   Recurrence{rrule, rdate, exdate},
+		RecurrenceId: recurrenceId,
+		RelatedTo:    relatedTo,
+		Status:       EventStatus(strings.ToUpper(status)),
+		Transparency: Transparency(strings.ToUpper(transparency)),
+		Categories:   categories,
+		Color:        strings.ToLower(eventColor),
+		Alarms:       alarms,
+		Organizer:    organizer,
+		Attendees:    attendees,
+		Extra:        extra,
+		ExtraParams:  extraParams,
+		Created:      created,
+		Modified:     modified,
 	}, nil
 }
 
+// icalTimeZone returns the IANA time zone of a date-time property, or an empty string if it is in UTC, floating or in an unknown time zone.
+func icalTimeZone(prop *ical.Prop) string {
+	if prop == nil || prop.ValueType() == ical.ValueDate {
+		return ""
+	}
+	if tzid := prop.Params.Get(ical.ParamTimezoneID); tzid != "" {
+		if _, err := time.LoadLocation(tzid); err == nil {
+			return tzid
+		}
+	}
+	return ""
+}
+
 func FromIcal(cal *ical.Calendar) ([]EventProperties, error) {
 	eventsProps := []EventProperties{}
 
@@ -75,61 +258,715 @@ func FromIcal(cal *ical.Calendar) ([]EventProperties, error) {
 	return eventsProps, nil
 }
 
+func FromIcalAlarm(icalAlarm *ical.Component) (Alarm, error) {
+	var alarm Alarm
+
+	action, err := icalAlarm.Props.Text(ical.PropAction)
+	if err != nil {
+		return Alarm{}, err
+	}
+	if action == "" {
+		return Alarm{}, errors.New("alarm is missing an action")
+	}
+	alarm.Action = AlarmAction(strings.ToUpper(action))
+
+	trigger := icalAlarm.Props.Get(ical.PropTrigger)
+	if trigger == nil {
+		return Alarm{}, errors.New("alarm is missing a trigger")
+	}
+	if trigger.ValueType() == ical.ValueDateTime {
+		if alarm.TriggerAt, err = trigger.DateTime(GetTimeZone()); err != nil {
+			return Alarm{}, err
+		}
+	} else {
+		if alarm.Trigger, err = trigger.Duration(); err != nil {
+			return Alarm{}, err
+		}
+		alarm.TriggerFromEnd = strings.ToUpper(trigger.Params.Get(ical.ParamRelated)) == "END"
+	}
+
+	if alarm.Description, err = icalAlarm.Props.Text(ical.PropDescription); err != nil {
+		return Alarm{}, err
+	}
+	if alarm.Summary, err = icalAlarm.Props.Text(ical.PropSummary); err != nil {
+		return Alarm{}, err
+	}
+	for _, prop := range icalAlarm.Props.Values(ical.PropAttendee) {
+		alarm.Attendees = append(alarm.Attendees, fromIcalAttendee(prop))
+	}
+
+	if prop := icalAlarm.Props.Get(ical.PropRepeat); prop != nil {
+		if alarm.Repeat, err = prop.Int(); err != nil {
+			return Alarm{}, err
+		}
+	}
+	if prop := icalAlarm.Props.Get(ical.PropDuration); prop != nil {
+		if alarm.RepeatInterval, err = prop.Duration(); err != nil {
+			return Alarm{}, err
+		}
+	}
+
+	alarm.Extra = readExtraProps(icalAlarm.Props, icalAlarmProps)
+
+	return alarm, nil
+}
+
+func FromIcalTodo(icalTodo *ical.Component) (TodoProperties, error) {
+	var uid, summary, description, location, url, status string
+
+	if err := readTextProps(icalTodo.Props, map[*string]string{
+		&uid:         ical.PropUID,
+		&summary:     ical.PropSummary,
+		&description: ical.PropDescription,
+		&location:    ical.PropLocation,
+		&status:      ical.PropStatus,
+	}); err != nil {
+		return TodoProperties{}, err
+	}
+
+	readRawProps(icalTodo.Props, map[*string]string{
+		&url: ical.PropURL,
+	})
+
+	start, err := icalTodo.Props.DateTime(ical.PropDateTimeStart, GetTimeZone())
+	if err != nil {
+		return TodoProperties{}, err
+	}
+	due, err := icalTodo.Props.DateTime(ical.PropDue, GetTimeZone())
+	if err != nil {
+		return TodoProperties{}, err
+	}
+	completed, err := icalTodo.Props.DateTime(ical.PropCompleted, GetTimeZone())
+	if err != nil {
+		return TodoProperties{}, err
+	}
+
+	var percentComplete, priority int
+	if prop := icalTodo.Props.Get(ical.PropPercentComplete); prop != nil {
+		if percentComplete, err = prop.Int(); err != nil {
+			return TodoProperties{}, err
+		}
+	}
+	if prop := icalTodo.Props.Get(ical.PropPriority); prop != nil {
+		if priority, err = prop.Int(); err != nil {
+			return TodoProperties{}, err
+		}
+	}
+
+	// Ignore errors for these, since they may not exist.
+	created, _ := icalTodo.Props.DateTime(ical.PropCreated, GetTimeZone())
+	modified, _ := icalTodo.Props.DateTime(ical.PropLastModified, GetTimeZone())
+
+	return TodoProperties{
+		Component:       ComponentTodo,
+		Uid:             uid,
+		Summary:         summary,
+		Description:     description,
+		Location:        location,
+		Url:             url,
+		Start:           start,
+		Due:             due,
+		Completed:       completed,
+		PercentComplete: percentComplete,
+		Priority:        priority,
+		Status:          TodoStatus(status),
+		Extra:           readExtraProps(icalTodo.Props, icalTodoProps),
+		Created:         created,
+		Modified:        modified,
+	}, nil
+}
+
+func FromIcalTodos(cal *ical.Calendar) ([]TodoProperties, error) {
+	todosProps := []TodoProperties{}
+
+	for _, child := range cal.Children {
+		if child.Name != ical.CompToDo {
+			continue
+		}
+		props, err := FromIcalTodo(child)
+		if err != nil {
+			return nil, err
+		}
+		todosProps = append(todosProps, props)
+	}
+
+	return todosProps, nil
+}
+
+// readTextProps reads the (unescaped) text values of properties into their destinations. Missing properties are left as is.
+func readTextProps(props ical.Props, textProps map[*string]string) error {
+	for dest, propName := range textProps {
+		if prop := props.Get(propName); prop != nil {
+			text, err := prop.Text()
+			if err != nil {
+				return err
+			}
+			*dest = text
+		}
+	}
+	return nil
+}
+
+// readRawProps reads the raw values of properties into their destinations. Missing properties are left as is.
+func readRawProps(props ical.Props, rawProps map[*string]string) {
+	for dest, propName := range rawProps {
+		if prop := props.Get(propName); prop != nil {
+			*dest = prop.Value
+		}
+	}
+}
+
+// icalFloatingDateTimeLayout is the layout of date-times without a time zone, which are in the event's time zone.
+const icalFloatingDateTimeLayout = "20060102T150405"
+
+// readDateListProps reads the values of all the properties (like EXDATE), joined by commas.
+// Date-times with a TZID are converted to UTC, since the recurrence dates are stored without their parameters.
+func readDateListProps(props ical.Props, name string) (string, error) {
+	values := []string{}
+	for _, prop := range props.Values(name) {
+		for _, value := range strings.Split(prop.Value, ",") {
+			if len(value) == len(icalFloatingDateTimeLayout) && prop.Params.Get(ical.ParamTimezoneID) != "" {
+				p := ical.Prop{Name: name, Value: value, Params: prop.Params}
+				t, err := p.DateTime(GetTimeZone())
+				if err != nil {
+					return "", err
+				}
+				value = t.In(time.UTC).Format("20060102T150405Z")
+			}
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, ","), nil
+}
+
+// toIcalDateList returns the recurrence dates with their date-times in UTC, since the floating date-times are in the
+// event's time zone, which other clients would not read them in.
+func toIcalDateList(dates string, loc *time.Location) string {
+	values := strings.Split(dates, ",")
+	for i, value := range values {
+		if t, err := time.ParseInLocation(icalFloatingDateTimeLayout, value, loc); err == nil {
+			values[i] = t.In(time.UTC).Format("20060102T150405Z")
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+// readExtraProps reads all properties that are not in known, sorted by name.
+func readExtraProps(props ical.Props, known []string) []ExtraProperty {
+	names := []string{}
+	for name := range props {
+		if !slices.Contains(known, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var extra []ExtraProperty
+	for _, name := range names {
+		for _, prop := range props[name] {
+			var params map[string][]string
+			if len(prop.Params) != 0 {
+				params = prop.Params
+			}
+			extra = append(extra, ExtraProperty{
+				Name:   name,
+				Value:  prop.Value,
+				Params: params,
+			})
+		}
+	}
+	return extra
+}
+
+// addExtraProps adds the extra properties, except those in known, which are written by ian itself.
+func addExtraProps(props ical.Props, extra []ExtraProperty, known []string) {
+	for _, extra := range extra {
+		name := strings.ToUpper(extra.Name)
+		if slices.Contains(known, name) {
+			continue
+		}
+		prop := ical.NewProp(name)
+		prop.Value = extra.Value
+		for param, values := range extra.Params {
+			prop.Params[strings.ToUpper(param)] = values
+		}
+		props.Add(prop)
+	}
+}
+
+// readExtraParams reads the parameters of the modelled property that ian does not model (see icalKnownParams).
+func readExtraParams(prop *ical.Prop, name string) map[string][]string {
+	var params map[string][]string
+	for param, values := range prop.Params {
+		if slices.Contains(icalKnownParams[name], param) {
+			continue
+		}
+		if params == nil {
+			params = map[string][]string{}
+		}
+		params[param] = values
+	}
+	return params
+}
+
+// setExtraParams sets the extra parameters of the property, except those that ian has set itself.
+func setExtraParams(prop *ical.Prop, params map[string][]string) {
+	for param, values := range params {
+		param = strings.ToUpper(param)
+		if _, ok := prop.Params[param]; !ok {
+			prop.Params[param] = values
+		}
+	}
+}
+
+// setRawProp sets a property to a value that is already formatted according to the property's value type.
+func setRawProp(props ical.Props, name, value string) {
+	prop := ical.NewProp(name)
+	prop.Value = value
+	props.Set(prop)
+}
+
 const IcalPropGrabTimestamp string = "X-IAN-GRABBED"
 
-func ToIcal(events []Event, calendarName string) *ical.Calendar {
+// IcalPropPublishedTtl is the non-standard predecessor of REFRESH-INTERVAL, which many feeds still use.
+const IcalPropPublishedTtl string = "X-PUBLISHED-TTL"
+
+// IcalCalendarInfo are the properties of an iCalendar that describe the calendar itself (RFC 7986).
+type IcalCalendarInfo struct {
+	// Name is the NAME, or the non-standard X-WR-CALNAME.
+	Name string
+	// Color is the COLOR, or zero if there is none.
+	Color color.RGBA
+	// RefreshInterval is the REFRESH-INTERVAL (or X-PUBLISHED-TTL), the suggested time between updates of the calendar.
+	RefreshInterval time.Duration
+	// Extra are the other properties of the calendar, like X-WR-TIMEZONE or CALSCALE.
+	Extra []ExtraProperty
+}
+
+// ReadIcalCalendarInfo reads the properties that describe the calendar.
+// Invalid properties are ignored, since they are only suggestions.
+func ReadIcalCalendarInfo(cal *ical.Calendar) IcalCalendarInfo {
+	var info IcalCalendarInfo
+
+	for _, name := range []string{ical.PropName, "X-WR-CALNAME"} {
+		if s, err := cal.Props.Text(name); err == nil && s != "" {
+			info.Name = s
+			break
+		}
+	}
+
+	if s, err := cal.Props.Text(ical.PropColor); err == nil {
+		info.Color, _ = ParseIcalColor(s)
+	}
+
+	for _, name := range []string{ical.PropRefreshInterval, IcalPropPublishedTtl} {
+		if prop := cal.Props.Get(name); prop != nil {
+			if d, err := prop.Duration(); err == nil && d > 0 {
+				info.RefreshInterval = d
+				break
+			}
+		}
+	}
+
+	info.Extra = readExtraProps(cal.Props, icalCalendarProps)
+
+	return info
+}
+
+// SetIcalCalendarExtra adds the extra properties that the iCalendar of a source calendar had at the source's last update
+// (see IcalCalendarInfo). Other calendars have none.
+func (instance *Instance) SetIcalCalendarExtra(cal *ical.Calendar, calendar string) {
+	source, ok := SourceOfCalendar(calendar)
+	if !ok {
+		return
+	}
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		return
+	}
+	addExtraProps(cal.Props, journal.Sources[source].Calendar.Extra, icalCalendarProps)
+}
+
+// SetIcalCalendarColor sets the COLOR of the iCalendar to the color of the calendar, if it has one configured.
+func (conf *Config) SetIcalCalendarColor(cal *ical.Calendar, calendar string) {
+	calendarConfig, err := conf.GetContainerConfig(calendar)
+	if err != nil || calendarConfig.Color == (color.RGBA{}) {
+		return
+	}
+	cal.Props.SetText(ical.PropColor, FormatIcalColor(calendarConfig.Color))
+}
+
+func ToIcal(events []Event, todos []Todo, calendarName string) *ical.Calendar {
 	cal := ical.NewCalendar()
 	cal.Props.SetText(ical.PropVersion, "2.0")
 	cal.Props.SetText(ical.PropProductID, "-//ian//ian calendar migration")
 
 	if calendarName != "" {
+		cal.Props.SetText(ical.PropName, calendarName)
 		cal.Props.SetText("X-WR-CALNAME", calendarName)
 	}
 
-	now := time.Now()
+	now := time.Now().In(time.UTC)
 	cal.Props.SetDateTime(IcalPropGrabTimestamp, now)
 
+	// timeZones are the time zones used by the events, with the years they are used in.
+	timeZones := map[*time.Location][2]int{}
+	var timeZoneOrder []*time.Location
+
 	for _, event := range events {
 		icalEvent := ical.NewEvent()
 
 		icalEvent.Props.SetText(ical.PropUID, event.Props.Uid)
 
-		icalEvent.Props.SetDateTime(ical.PropCreated, event.Props.Created)
-		icalEvent.Props.SetDateTime(ical.PropLastModified, event.Props.Modified)
+		icalEvent.Props.SetDateTime(ical.PropCreated, event.Props.Created.In(time.UTC))
+		icalEvent.Props.SetDateTime(ical.PropLastModified, event.Props.Modified.In(time.UTC))
 
 		icalEvent.Props.SetDateTime(ical.PropDateTimeStamp, now)
 
+		loc := icalLocation(&event.Props)
+		start := event.Props.Start.In(loc)
+		end := event.Props.End.In(loc)
+
 		if !event.Props.IsAllDay() {
-			icalEvent.Props.SetDateTime(ical.PropDateTimeStart, event.Props.Start)
-			icalEvent.Props.SetDateTime(ical.PropDateTimeEnd, event.Props.End)
+			icalEvent.Props.SetDateTime(ical.PropDateTimeStart, start)
+			icalEvent.Props.SetDateTime(ical.PropDateTimeEnd, end)
+
+			if loc != time.UTC {
+				years, ok := timeZones[loc]
+				if !ok {
+					years = [2]int{start.Year(), end.Year()}
+					timeZoneOrder = append(timeZoneOrder, loc)
+				}
+				years[0] = min(years[0], start.Year())
+				years[1] = max(years[1], end.Year())
+				if event.Props.Recurrence.IsThereRecurrence() {
+					// The recurrences may go on, so include the coming years too.
+					years[1] = max(years[1], now.Year()+1)
+				}
+				timeZones[loc] = years
+			}
 		} else {
 			icalEvent.Props.SetDate(ical.PropDateTimeStart, event.Props.Start)
 			icalEvent.Props.SetDate(ical.PropDateTimeEnd, event.Props.Start.AddDate(0, 0, 1))
 		}
 
+		if event.Props.IsOverride() {
+			if !event.Props.IsAllDay() {
+				icalEvent.Props.SetDateTime(ical.PropRecurrenceID, event.Props.RecurrenceId.In(loc))
+			} else {
+				icalEvent.Props.SetDate(ical.PropRecurrenceID, event.Props.RecurrenceId)
+			}
+		}
+
 		icalEvent.Props.SetText(ical.PropSummary, event.Props.Summary)
 
 		optionalProps := map[string]string{
-			event.Props.Description:       ical.PropDescription,
-			event.Props.Location:          ical.PropLocation,
-			event.Props.Url:               ical.PropURL,
-			event.Props.Recurrence.RRule:  ical.PropRecurrenceRule,
-			event.Props.Recurrence.RDate:  ical.PropRecurrenceDates,
-			event.Props.Recurrence.ExDate: ical.PropExceptionDates,
+			ical.PropDescription:  event.Props.Description,
+			ical.PropLocation:     event.Props.Location,
+			ical.PropStatus:       string(event.Props.Status),
+			ical.PropTransparency: string(event.Props.Transparency),
+			ical.PropRelatedTo:    event.Props.RelatedTo,
+			ical.PropColor:        event.Props.Color,
 		}
 
-		for value, assignAs := range optionalProps {
+		for assignAs, value := range optionalProps {
 			if value != "" {
 				icalEvent.Props.SetText(assignAs, value)
 			}
 		}
 
+		optionalRawProps := map[string]string{
+			ical.PropURL:            event.Props.Url,
+			ical.PropRecurrenceRule: event.Props.Recurrence.RRule,
+		}
+		if event.Props.Recurrence.RDate != "" {
+			optionalRawProps[ical.PropRecurrenceDates] = toIcalDateList(event.Props.Recurrence.RDate, event.Props.GetTimeZoneLocation())
+		}
+		if event.Props.Recurrence.ExDate != "" {
+			optionalRawProps[ical.PropExceptionDates] = toIcalDateList(event.Props.Recurrence.ExDate, event.Props.GetTimeZoneLocation())
+		}
+
+		for assignAs, value := range optionalRawProps {
+			if value != "" {
+				setRawProp(icalEvent.Props, assignAs, value)
+			}
+		}
+
+		if len(event.Props.Categories) != 0 {
+			categories := ical.NewProp(ical.PropCategories)
+			categories.SetTextList(event.Props.Categories)
+			icalEvent.Props.Set(categories)
+		}
+
+		if event.Props.Organizer.Address != "" {
+			organizer := ical.NewProp(ical.PropOrganizer)
+			organizer.Value = event.Props.Organizer.Address
+			if event.Props.Organizer.Name != "" {
+				organizer.Params.Set(ical.ParamCommonName, event.Props.Organizer.Name)
+			}
+			setExtraParams(organizer, event.Props.Organizer.Params)
+			icalEvent.Props.Set(organizer)
+		}
+
+		for _, attendee := range event.Props.Attendees {
+			icalEvent.Props.Add(toIcalAttendee(attendee))
+		}
+
+		addExtraProps(icalEvent.Props, event.Props.Extra, icalEventProps)
+		for name, params := range event.Props.ExtraParams {
+			if name = strings.ToUpper(name); name == ical.PropOrganizer || name == ical.PropAttendee {
+				continue
+			}
+			if prop := icalEvent.Props.Get(name); prop != nil {
+				setExtraParams(prop, params)
+			}
+		}
+
+		for _, alarm := range event.Props.Alarms {
+			icalEvent.Children = append(icalEvent.Children, toIcalAlarm(alarm, event.Props.Summary))
+		}
+
 		cal.Children = append(cal.Children, icalEvent.Component)
 	}
 
+	for _, todo := range todos {
+		cal.Children = append(cal.Children, toIcalTodo(todo.Props, now))
+	}
+
+	for _, loc := range timeZoneOrder {
+		years := timeZones[loc]
+		cal.Children = append(cal.Children, toIcalTimeZone(loc, years[0], years[1]))
+	}
+
 	return cal
 }
 
+func fromIcalAttendee(prop ical.Prop) Attendee {
+	return Attendee{
+		Address: prop.Value,
+		Name:    prop.Params.Get(ical.ParamCommonName),
+		Role:    strings.ToUpper(prop.Params.Get(ical.ParamRole)),
+		Status:  ParticipationStatus(strings.ToUpper(prop.Params.Get(ical.ParamParticipationStatus))),
+		Rsvp:    strings.ToUpper(prop.Params.Get(ical.ParamRSVP)) == "TRUE",
+		Params:  readExtraParams(&prop, ical.PropAttendee),
+	}
+}
+
+// icalLocation returns the location that an event's times are written in.
+// Events without a time zone are written in UTC, unless the global time zone is a named IANA time zone.
+func icalLocation(props *EventProperties) *time.Location {
+	if props.TimeZone != "" {
+		return props.GetTimeZoneLocation()
+	}
+	if loc := GetTimeZone(); loc != time.Local {
+		if named, err := time.LoadLocation(loc.String()); err == nil {
+			return named
+		}
+	}
+	return time.UTC
+}
+
+// toIcalTimeZone creates a VTIMEZONE component for loc, with its offset transitions during the years from and to (inclusive).
+func toIcalTimeZone(loc *time.Location, from, to int) *ical.Component {
+	icalTimeZone := ical.NewComponent(ical.CompTimezone)
+	icalTimeZone.Props.SetText(ical.PropTimezoneID, loc.String())
+
+	t := time.Date(from, 1, 1, 0, 0, 0, 0, loc)
+	end := time.Date(to+1, 1, 1, 0, 0, 0, 0, loc)
+
+	for {
+		transition, ok := nextOffsetTransition(t, end)
+		if !ok {
+			break
+		}
+		icalTimeZone.Children = append(icalTimeZone.Children, toIcalTimeZoneObservance(transition.Add(-time.Second), transition))
+		t = transition
+	}
+
+	if len(icalTimeZone.Children) == 0 {
+		// The time zone has no transitions, so it is always observed the same way.
+		observance := toIcalTimeZoneObservance(t, t)
+		setRawProp(observance.Props, ical.PropDateTimeStart, "19700101T000000")
+		icalTimeZone.Children = append(icalTimeZone.Children, observance)
+	}
+
+	return icalTimeZone
+}
+
+// toIcalTimeZoneObservance creates a STANDARD or DAYLIGHT component for the change of offset from before to at.
+func toIcalTimeZoneObservance(before, at time.Time) *ical.Component {
+	name := ical.CompTimezoneStandard
+	if at.IsDST() {
+		name = ical.CompTimezoneDaylight
+	}
+	observance := ical.NewComponent(name)
+
+	abbreviation, _ := at.Zone()
+	_, offsetFrom := before.Zone()
+
+	// The onset is in the local time that is observed before it.
+	setRawProp(observance.Props, ical.PropDateTimeStart, at.In(time.FixedZone("", offsetFrom)).Format("20060102T150405"))
+	setRawProp(observance.Props, ical.PropTimezoneOffsetFrom, formatUtcOffset(before))
+	setRawProp(observance.Props, ical.PropTimezoneOffsetTo, formatUtcOffset(at))
+	observance.Props.SetText(ical.PropTimezoneName, abbreviation)
+
+	return observance
+}
+
+// nextOffsetTransition finds the first time after t and before end where the UTC offset changes.
+func nextOffsetTransition(t, end time.Time) (time.Time, bool) {
+	_, offset := t.Zone()
+	for day := t; day.Before(end); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		if _, o := next.Zone(); o == offset {
+			continue
+		}
+		// The offset changes during this day: narrow it down to the second.
+		lo, hi := day.Unix(), next.Unix()
+		for hi-lo > 1 {
+			mid := (lo + hi) / 2
+			if _, o := time.Unix(mid, 0).In(t.Location()).Zone(); o == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		return time.Unix(hi, 0).In(t.Location()), true
+	}
+	return time.Time{}, false
+}
+
+func formatUtcOffset(t time.Time) string {
+	_, offset := t.Zone()
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset/60%60)
+}
+
+func toIcalAttendee(attendee Attendee) *ical.Prop {
+	prop := ical.NewProp(ical.PropAttendee)
+	prop.Value = attendee.Address
+
+	optionalParams := map[string]string{
+		ical.ParamCommonName:          attendee.Name,
+		ical.ParamRole:                attendee.Role,
+		ical.ParamParticipationStatus: string(attendee.Status),
+	}
+
+	for assignAs, value := range optionalParams {
+		if value != "" {
+			prop.Params.Set(assignAs, value)
+		}
+	}
+
+	if attendee.Rsvp {
+		prop.Params.Set(ical.ParamRSVP, "TRUE")
+	}
+	setExtraParams(prop, attendee.Params)
+
+	return prop
+}
+
+// toIcalAlarm creates a VALARM component. summary is used as the description of display and email alarms without one,
+// and as the summary of email alarms without one, since they are required.
+func toIcalAlarm(alarm Alarm, summary string) *ical.Component {
+	icalAlarm := ical.NewComponent(ical.CompAlarm)
+
+	icalAlarm.Props.SetText(ical.PropAction, string(alarm.Action))
+
+	trigger := ical.NewProp(ical.PropTrigger)
+	if !alarm.TriggerAt.IsZero() {
+		trigger.SetDateTime(alarm.TriggerAt.In(time.UTC))
+	} else {
+		trigger.SetDuration(alarm.Trigger)
+		if alarm.TriggerFromEnd {
+			trigger.Params.Set(ical.ParamRelated, "END")
+		}
+	}
+	icalAlarm.Props.Set(trigger)
+
+	if alarm.Description != "" {
+		icalAlarm.Props.SetText(ical.PropDescription, alarm.Description)
+	} else if alarm.Action == AlarmActionDisplay || alarm.Action == AlarmActionEmail {
+		icalAlarm.Props.SetText(ical.PropDescription, summary)
+	}
+
+	if alarm.Summary != "" {
+		icalAlarm.Props.SetText(ical.PropSummary, alarm.Summary)
+	} else if alarm.Action == AlarmActionEmail {
+		icalAlarm.Props.SetText(ical.PropSummary, summary)
+	}
+	for _, attendee := range alarm.Attendees {
+		icalAlarm.Props.Add(toIcalAttendee(attendee))
+	}
+
+	if alarm.Repeat > 0 {
+		setRawProp(icalAlarm.Props, ical.PropRepeat, fmt.Sprint(alarm.Repeat))
+		duration := ical.NewProp(ical.PropDuration)
+		duration.SetDuration(alarm.RepeatInterval)
+		icalAlarm.Props.Set(duration)
+	}
+
+	addExtraProps(icalAlarm.Props, alarm.Extra, icalAlarmProps)
+
+	return icalAlarm
+}
+
+func toIcalTodo(props TodoProperties, now time.Time) *ical.Component {
+	icalTodo := ical.NewComponent(ical.CompToDo)
+
+	icalTodo.Props.SetText(ical.PropUID, props.Uid)
+
+	icalTodo.Props.SetDateTime(ical.PropCreated, props.Created.In(time.UTC))
+	icalTodo.Props.SetDateTime(ical.PropLastModified, props.Modified.In(time.UTC))
+
+	icalTodo.Props.SetDateTime(ical.PropDateTimeStamp, now)
+
+	icalTodo.Props.SetText(ical.PropSummary, props.Summary)
+
+	optionalProps := map[string]string{
+		ical.PropDescription: props.Description,
+		ical.PropLocation:    props.Location,
+		ical.PropStatus:      string(props.Status),
+	}
+
+	for assignAs, value := range optionalProps {
+		if value != "" {
+			icalTodo.Props.SetText(assignAs, value)
+		}
+	}
+
+	if props.Url != "" {
+		setRawProp(icalTodo.Props, ical.PropURL, props.Url)
+	}
+
+	optionalDateTimes := map[string]time.Time{
+		ical.PropDateTimeStart: props.Start,
+		ical.PropDue:           props.Due,
+		ical.PropCompleted:     props.Completed,
+	}
+
+	for assignAs, value := range optionalDateTimes {
+		if !value.IsZero() {
+			icalTodo.Props.SetDateTime(assignAs, value.In(time.UTC))
+		}
+	}
+
+	if props.PercentComplete != 0 {
+		setRawProp(icalTodo.Props, ical.PropPercentComplete, fmt.Sprint(props.PercentComplete))
+	}
+	if props.Priority != 0 {
+		setRawProp(icalTodo.Props, ical.PropPriority, fmt.Sprint(props.Priority))
+	}
+
+	addExtraProps(icalTodo.Props, props.Extra, icalTodoProps)
+
+	return icalTodo
+}
+
 func ParseIcal(r io.Reader) (*ical.Calendar, error) {
 	ics, err := ical.NewDecoder(r).Decode()
 	if err != nil {
@@ -1,6 +1,12 @@
 package ian
 
 import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -19,23 +25,469 @@ func TestMigrateToThenFromIcal(t *testing.T) {
 		Recurrence: Recurrence{
 			RRule: "FREQ=DAILY;INTERVAL=3;COUNT=12",
 		},
+		Status:       EventStatusTentative,
+		Transparency: TransparencyTransparent,
+		Categories:   []string{"work", "with, comma"},
+		Color:        "turquoise",
+		Alarms: []Alarm{
+			{
+				Action:      AlarmActionDisplay,
+				Trigger:     -15 * time.Minute,
+				Description: "reminder",
+			},
+			{
+				Action:         AlarmActionAudio,
+				Trigger:        5 * time.Minute,
+				TriggerFromEnd: true,
+				Repeat:         2,
+				RepeatInterval: time.Minute,
+			},
+			{
+				Action:      AlarmActionDisplay,
+				TriggerAt:   now.Add(-time.Hour),
+				Description: "absolute",
+			},
+			{
+				Action:      AlarmActionEmail,
+				Trigger:     -24 * time.Hour,
+				Description: "tomorrow",
+				Summary:     "reminder",
+				Attendees:   []Attendee{{Address: "mailto:jane@example.com", Name: "Jane"}},
+			},
+		},
+		Organizer: Organizer{
+			Address: "mailto:boss@example.com",
+			Name:    "Boss",
+		},
+		Attendees: []Attendee{
+			{
+				Address: "mailto:jane@example.com",
+				Name:    "Doe, Jane",
+				Role:    "REQ-PARTICIPANT",
+				Status:  PartStatNeedsAction,
+				Rsvp:    true,
+			},
+			{
+				Address: "mailto:joe@example.com",
+				Status:  PartStatDeclined,
+			},
+		},
+		Extra: []ExtraProperty{
+			{Name: "CLASS", Value: "PRIVATE"},
+			{Name: "GEO", Value: "59.3293;18.0686"},
+			{Name: "SEQUENCE", Value: "3"},
+			{Name: "X-ALT-DESC", Value: "<p>description</p>", Params: map[string][]string{"FMTTYPE": {"text/html"}}},
+			{Name: "X-VENDOR", Value: "one\\, escaped"},
+			{Name: "X-VENDOR", Value: "two"},
+		},
 		Created:  now,
 		Modified: now,
 	}
 
+	override := props
+	override.Recurrence = Recurrence{}
+	override.RecurrenceId = now.AddDate(0, 0, 3)
+	override.Start = override.RecurrenceId.Add(time.Hour)
+	override.End = override.Start.Add(time.Hour)
+
 	events := []Event{
 		{
 			Props: props,
 		},
+		{
+			Props: override,
+		},
 	}
 
-	ical := ToIcal(events, "")
+	ical := ToIcal(events, nil, "")
 	native, err := FromIcal(ical)
 	if err != nil {
 		t.Error(err)
 	}
 
-	if native[0] != props {
+	if !reflect.DeepEqual(native[0], props) {
+		t.Errorf("migration to ical and back failed:\n\ngot:  %+v\nwant: %+v", native[0], props)
+	}
+	if !reflect.DeepEqual(native[1], override) {
+		t.Errorf("migration of override to ical and back failed:\n\ngot:  %+v\nwant: %+v", native[1], override)
+	}
+}
+
+func TestMigrateTodoToThenFromIcal(t *testing.T) {
+	now := time.Now().In(time.UTC).Truncate(time.Second)
+
+	props := TodoProperties{
+		Component:       ComponentTodo,
+		Uid:             GenerateUid(),
+		Summary:         "summary",
+		Description:     "description, with; escapes",
+		Due:             now.Add(48 * time.Hour),
+		Completed:       now,
+		PercentComplete: 100,
+		Priority:        3,
+		Status:          TodoStatusCompleted,
+		Extra:           []ExtraProperty{{Name: "CLASS", Value: "PRIVATE"}},
+		Created:         now,
+		Modified:        now,
+	}
+
+	todos := []Todo{
+		{
+			Props: props,
+		},
+	}
+
+	ical := ToIcal(nil, todos, "")
+	native, err := FromIcalTodos(ical)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(native[0], props) {
 		t.Errorf("migration to ical and back failed:\n\ngot:  %+v\nwant: %+v", native[0], props)
 	}
 }
+
+func TestIcalAlarms(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//test",
+		"BEGIN:VEVENT",
+		"UID:alarms@example.com",
+		"DTSTAMP:20240101T000000Z",
+		"DTSTART:20240506T090000Z",
+		"DTEND:20240506T100000Z",
+		"SUMMARY:Planning",
+		"BEGIN:VALARM",
+		"ACTION:EMAIL",
+		"TRIGGER:-PT1H",
+		"ATTENDEE:mailto:jane@example.com",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:no trigger",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	cal, err := ParseIcal(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := FromIcal(cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported[0].Alarms) != 1 || imported[0].Alarms[0].Action != AlarmActionEmail {
+		t.Fatalf("expected only the malformed alarm to be ignored, got %+v", imported[0].Alarms)
+	}
+	if err := imported[0].Validate(); err != nil {
+		t.Errorf("expected the email alarm to be valid: %s", err)
+	}
+
+	exported, err := FromIcal(ToIcal([]Event{{Props: imported[0]}}, nil, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alarm := exported[0].Alarms[0]; alarm.Summary != "Planning" || alarm.Description != "Planning" || len(alarm.Attendees) != 1 {
+		t.Errorf("expected the email alarm to get the required properties, got %+v", alarm)
+	}
+}
+
+func TestMigrateTimeZoneToThenFromIcal(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	start := time.Date(2024, 3, 25, 9, 0, 0, 0, loc)
+
+	props := EventProperties{
+		Uid:        GenerateUid(),
+		Summary:    "weekly",
+		Start:      start,
+		End:        start.Add(time.Hour),
+		TimeZone:   loc.String(),
+		Recurrence: Recurrence{RRule: "FREQ=WEEKLY;COUNT=3"},
+		Created:    start.In(time.UTC),
+		Modified:   start.In(time.UTC),
+	}
+
+	cal := ToIcal([]Event{{Props: props}}, nil, "")
+
+	var timeZones int
+	for _, child := range cal.Children {
+		if child.Name == "VTIMEZONE" {
+			timeZones++
+			if tzid := child.Props.Get("TZID"); tzid == nil || tzid.Value != loc.String() {
+				t.Errorf("VTIMEZONE has wrong TZID: %v", tzid)
+			}
+		}
+	}
+	if timeZones != 1 {
+		t.Errorf("expected 1 VTIMEZONE, got %d", timeZones)
+	}
+
+	native, err := FromIcal(cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(native[0], props) {
+		t.Errorf("migration to ical and back failed:\n\ngot:  %+v\nwant: %+v", native[0], props)
+	}
+
+	// The occurrences keep the local time across the change to daylight saving time.
+	set, err := native[0].GetRruleSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, occurrence := range set.All() {
+		if h, m, _ := occurrence.In(loc).Clock(); h != 9 || m != 0 {
+			t.Errorf("occurrence %s drifted from 09:00", occurrence)
+		}
+	}
+}
+
+func TestIcalFileRoundTrip(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//test",
+		"X-WR-TIMEZONE:Europe/Stockholm",
+		"BEGIN:VEVENT",
+		"UID:round-trip@example.com",
+		"DTSTAMP:20240101T000000Z",
+		"DTSTART:20240506T090000Z",
+		"DTEND:20240506T100000Z",
+		"SUMMARY:Planning\\, part 1",
+		"DESCRIPTION;ALTREP=\"cid:part1.0001@example.org\";LANGUAGE=en:The agenda",
+		"ORGANIZER;CN=Boss;SENT-BY=\"mailto:assistant@example.com\":mailto:boss@example.com",
+		"ATTENDEE;CN=Jane;CUTYPE=INDIVIDUAL;DELEGATED-TO=\"mailto:joe@example.com\":mailto:jane@example.com",
+		"CLASS:CONFIDENTIAL",
+		"GEO:37.386013;-122.082932",
+		"SEQUENCE:2",
+		"X-MICROSOFT-CDO-BUSYSTATUS:BUSY",
+		"X-APPLE-STRUCTURED-LOCATION;VALUE=URI;X-TITLE=Office:geo:37.386013,-122.082932",
+		"CREATED:20240101T000000Z",
+		"LAST-MODIFIED:20240102T000000Z",
+		"BEGIN:VALARM",
+		"ACTION:EMAIL",
+		"TRIGGER:-PT1H",
+		"ATTENDEE:mailto:jane@example.com",
+		"X-WR-ALARMUID:alarm-1",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"UID:todo@example.com",
+		"DTSTAMP:20240101T000000Z",
+		"SUMMARY:Prepare",
+		"CLASS:PRIVATE",
+		"END:VTODO",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	cal, err := ParseIcal(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := FromIcal(cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported[0].Extra) != 5 {
+		t.Fatalf("expected 5 extra properties, got %+v", imported[0].Extra)
+	}
+	importedTodos, err := FromIcalTodos(cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := ReadIcalCalendarInfo(cal)
+
+	// Through a file, like the cache.
+	file := filepath.Join(t.TempDir(), "event")
+	if err := imported[0].Write(file); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := parseEvent(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := importedTodos[0].Write(file); err != nil {
+		t.Fatal(err)
+	}
+	if buf, err = os.ReadFile(file); err != nil {
+		t.Fatal(err)
+	}
+	storedTodo, err := parseTodo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The calendar's properties are kept in the journal, like for a source.
+	instance := &Instance{Root: t.TempDir()}
+	if err := instance.writeCacheJournal(CacheJournal{Sources: map[string]CacheJournalSource{"team": {Calendar: info}}}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	exportedCal := ToIcal([]Event{{Props: stored}}, []Todo{{Props: storedTodo}}, "")
+	instance.SetIcalCalendarExtra(exportedCal, ".team")
+
+	out, err := SerializeIcal(exportedCal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cal, err = ParseIcal(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := FromIcal(cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(exported[0].Extra, imported[0].Extra) {
+		t.Errorf("extra properties changed:\n\ngot:  %+v\nwant: %+v", exported[0].Extra, imported[0].Extra)
+	}
+	if len(imported[0].ExtraParams["DESCRIPTION"]) != 2 || !reflect.DeepEqual(exported[0].ExtraParams, imported[0].ExtraParams) {
+		t.Errorf("extra parameters changed:\n\ngot:  %+v\nwant: %+v", exported[0].ExtraParams, imported[0].ExtraParams)
+	}
+	if len(imported[0].Attendees[0].Params) != 2 || !reflect.DeepEqual(exported[0].Attendees, imported[0].Attendees) {
+		t.Errorf("attendees changed:\n\ngot:  %+v\nwant: %+v", exported[0].Attendees, imported[0].Attendees)
+	}
+	if imported[0].Organizer.Params == nil || !reflect.DeepEqual(exported[0].Organizer, imported[0].Organizer) {
+		t.Errorf("organizer changed:\n\ngot:  %+v\nwant: %+v", exported[0].Organizer, imported[0].Organizer)
+	}
+	if len(imported[0].Alarms[0].Extra) != 1 || !reflect.DeepEqual(exported[0].Alarms[0].Extra, imported[0].Alarms[0].Extra) {
+		t.Errorf("extra alarm properties changed:\n\ngot:  %+v\nwant: %+v", exported[0].Alarms[0].Extra, imported[0].Alarms[0].Extra)
+	}
+	exportedTodos, err := FromIcalTodos(cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(importedTodos[0].Extra) != 1 || !reflect.DeepEqual(exportedTodos[0].Extra, importedTodos[0].Extra) {
+		t.Errorf("extra to-do properties changed:\n\ngot:  %+v\nwant: %+v", exportedTodos[0].Extra, importedTodos[0].Extra)
+	}
+	if len(info.Extra) != 1 || !reflect.DeepEqual(ReadIcalCalendarInfo(cal).Extra, info.Extra) {
+		t.Errorf("extra calendar properties changed:\n\ngot:  %+v\nwant: %+v", ReadIcalCalendarInfo(cal).Extra, info.Extra)
+	}
+	if exported[0].Summary != "Planning, part 1" {
+		t.Errorf("summary changed: %q", exported[0].Summary)
+	}
+}
+
+func TestIcalRecurrenceDatesWithTimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//test",
+		"BEGIN:VEVENT",
+		"UID:weekly@example.com",
+		"DTSTAMP:20240101T000000Z",
+		"DTSTART;TZID=Europe/Stockholm:20240325T090000",
+		"DTEND;TZID=Europe/Stockholm:20240325T100000",
+		"SUMMARY:Weekly",
+		"RRULE:FREQ=WEEKLY;COUNT=3",
+		"EXDATE;TZID=Europe/London:20240401T080000",
+		"RDATE;TZID=Europe/Stockholm:20240403T140000",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	expected := []time.Time{
+		time.Date(2024, 3, 25, 9, 0, 0, 0, loc),
+		time.Date(2024, 4, 3, 14, 0, 0, 0, loc),
+		time.Date(2024, 4, 8, 9, 0, 0, 0, loc),
+	}
+	occurrences := func(props EventProperties) []time.Time {
+		set, err := props.GetRruleSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return set.All()
+	}
+
+	cal, err := ParseIcal(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := FromIcal(cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := occurrences(imported[0]); !slices.EqualFunc(got, expected, time.Time.Equal) {
+		t.Errorf("expected the exception and recurrence dates in their time zone: %v, got %v", expected, got)
+	}
+
+	// Dates without a time zone are in the event's, which other clients would not read them in.
+	imported[0].Recurrence.ExDate = "20240401T090000"
+	imported[0].Recurrence.RDate = "20240403T140000"
+	out, err := SerializeIcal(ToIcal([]Event{{Props: imported[0]}}, nil, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"EXDATE:20240401T070000Z", "RDATE:20240403T120000Z"} {
+		if !strings.Contains(out.String(), line+"\r\n") {
+			t.Errorf("expected %q in the exported calendar:\n%s", line, out.String())
+		}
+	}
+	if cal, err = ParseIcal(&out); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := FromIcal(cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := occurrences(exported[0]); !slices.EqualFunc(got, expected, time.Time.Equal) {
+		t.Errorf("expected the same occurrences after the round trip: %v, got %v", expected, got)
+	}
+}
+
+func TestIcalCalendarInfo(t *testing.T) {
+	cal, err := ParseIcal(strings.NewReader("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\n" +
+		"NAME:Team\r\nCOLOR:Turquoise\r\nX-PUBLISHED-TTL:PT1H\r\nREFRESH-INTERVAL;VALUE=DURATION:P1D\r\nX-WR-TIMEZONE:Europe/Stockholm\r\n" +
+		"END:VCALENDAR\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := ReadIcalCalendarInfo(cal)
+	expected := IcalCalendarInfo{
+		Name:            "Team",
+		Color:           color.RGBA{64, 224, 208, 255},
+		RefreshInterval: 24 * time.Hour,
+		Extra:           []ExtraProperty{{Name: "X-WR-TIMEZONE", Value: "Europe/Stockholm"}},
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("expected %v, got %v", expected, info)
+	}
+
+	if name := FormatIcalColor(color.RGBA{153, 90, 209, 255}); name != "mediumpurple" {
+		t.Errorf("expected the closest color name, got '%s'", name)
+	}
+	if rgb, ok := ParseIcalColor("#995AD1"); !ok || rgb != (color.RGBA{153, 90, 209, 255}) {
+		t.Errorf("expected the hexadecimal color, got %v", rgb)
+	}
+
+	config := Config{Calendars: map[string]CalendarConfig{"work": {Color: color.RGBA{64, 224, 208, 255}}}}
+	out := ToIcal(nil, nil, "work")
+	config.SetIcalCalendarColor(out, "work")
+	if name, _ := out.Props.Text("NAME"); name != "work" {
+		t.Errorf("expected the calendar name, got '%s'", name)
+	}
+	if c, _ := out.Props.Text("COLOR"); c != "turquoise" {
+		t.Errorf("expected the calendar color, got '%s'", c)
+	}
+}
@@ -0,0 +1,200 @@
+package ian
+
+import (
+	"encoding/gob"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The index keeps the parsed events and to-dos of the calendar files, so that only the files that have changed since
+// the last command are parsed again.
+
+// IndexFilename is the name of the index file in the root.
+const IndexFilename = ".index"
+
+// IndexVersion is the version of the index format. It must be increased when EventProperties or TodoProperties
+// (or any type they contain) change, so that old indexes are rebuilt instead of missing the new properties.
+const IndexVersion = 1
+
+// indexRacyWindow is how long a file is not indexed after it was modified,
+// since it could be modified again within the resolution of its mtime without its size changing.
+const indexRacyWindow = 2 * time.Second
+
+// indexedFile is a parsed calendar file.
+type indexedFile struct {
+	ModTime time.Time
+	Size    int64
+
+	// Component is ComponentTodo for to-dos, and empty for events.
+	Component string
+	Event     EventProperties
+	Todo      TodoProperties
+}
+
+// indexFile is the content of the index file.
+type indexFile struct {
+	Version int
+	// Files are keyed by directory (relative to the root), and then by file name.
+	Files map[string]map[string]indexedFile
+}
+
+// eventIndex is the index of an instance. It is loaded when it is first used.
+type eventIndex struct {
+	root string
+
+	mu     sync.Mutex
+	loaded bool
+	dirty  bool
+	files  map[string]map[string]indexedFile
+}
+
+func newEventIndex(root string) *eventIndex {
+	return &eventIndex{root: root}
+}
+
+func (index *eventIndex) path() string {
+	return filepath.Join(index.root, IndexFilename)
+}
+
+// load reads the index file. A missing, corrupt or old index is rebuilt from scratch.
+func (index *eventIndex) load() {
+	if index.loaded {
+		return
+	}
+	index.loaded = true
+	index.files = map[string]map[string]indexedFile{}
+
+	f, err := os.Open(index.path())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("warning: index could not be read and will be rebuilt: %s\n", err)
+		return
+	}
+	defer f.Close()
+
+	var content indexFile
+	if err := gob.NewDecoder(f).Decode(&content); err != nil {
+		if Verbose {
+			log.Printf("index is corrupt and will be rebuilt: %s\n", err)
+		}
+		index.dirty = true
+		return
+	}
+	if content.Version != IndexVersion {
+		if Verbose {
+			log.Printf("index is from version %d (not %d) and will be rebuilt\n", content.Version, IndexVersion)
+		}
+		index.dirty = true
+		return
+	}
+
+	for dir, files := range content.Files {
+		for name, file := range files {
+			// The time zones are not kept, only their offsets.
+			file.Event.restoreTimeZone()
+			files[name] = file
+		}
+		index.files[dir] = files
+	}
+}
+
+// readDir returns the parsed files of the directory, reusing those in the index whose mtime and size are unchanged.
+func (index *eventIndex) readDir(dir string, names []string, parse func(name string) (indexedFile, bool)) map[string]indexedFile {
+	key, err := filepath.Rel(index.root, dir)
+	if err != nil {
+		key = dir
+	}
+
+	index.mu.Lock()
+	index.load()
+	indexed := index.files[key]
+	index.mu.Unlock()
+
+	files := make(map[string]indexedFile, len(names))
+	changed := false
+	now := time.Now()
+
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err == nil {
+			if file, ok := indexed[name]; ok && file.ModTime.Equal(info.ModTime()) && file.Size == info.Size() {
+				files[name] = file
+				continue
+			}
+		}
+
+		file, ok := parse(name)
+		if !ok {
+			continue
+		}
+		if err != nil || now.Sub(info.ModTime()) < indexRacyWindow {
+			// Parsed again next time.
+			file.ModTime = time.Time{}
+		} else {
+			file.ModTime = info.ModTime()
+			file.Size = info.Size()
+		}
+		files[name] = file
+		changed = true
+	}
+
+	// Every file left was reused, so the same number means the same files.
+	if changed || len(files) != len(indexed) {
+		index.mu.Lock()
+		index.files[key] = files
+		index.dirty = true
+		index.mu.Unlock()
+	}
+	return files
+}
+
+// save writes the index file if it has changed. Directories that no longer exist are left out.
+func (index *eventIndex) save() error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if !index.dirty {
+		return nil
+	}
+	for dir := range index.files {
+		if _, err := os.Stat(filepath.Join(index.root, dir)); os.IsNotExist(err) {
+			delete(index.files, dir)
+		}
+	}
+
+	// Written to a temporary file first, so that other commands never read a partial index.
+	f, err := os.CreateTemp(index.root, IndexFilename+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := gob.NewEncoder(f).Encode(indexFile{Version: IndexVersion, Files: index.files}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), index.path()); err != nil {
+		return err
+	}
+
+	index.dirty = false
+	return nil
+}
+
+// saveIndex writes the index of the instance, if it uses one. Failing to write it only makes the next command slower,
+// so it is only a warning.
+func (instance *Instance) saveIndex() {
+	if instance.index == nil {
+		return
+	}
+	if err := instance.index.save(); err != nil {
+		log.Printf("warning: index could not be written: %s\n", err)
+	}
+}
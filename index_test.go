@@ -0,0 +1,96 @@
+package ian
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventIndex(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "work", "standup")
+	past := time.Now().Add(-time.Hour)
+
+	write := func(summary string) {
+		props := EventProperties{
+			Uid:      "1",
+			Summary:  summary,
+			Start:    time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC),
+			End:      time.Date(2024, 5, 6, 9, 15, 0, 0, time.UTC),
+			TimeZone: "Europe/Stockholm",
+		}
+		if err := props.Write(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func() EventProperties {
+		instance := &Instance{Root: root, index: newEventIndex(root)}
+		events, _, err := instance.ReadEvents(TimeRange{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+		return events[0].Props
+	}
+
+	write("standup")
+	read()
+	if _, err := os.Stat(filepath.Join(root, IndexFilename)); err != nil {
+		t.Fatal("expected the index to be written")
+	}
+
+	// Same mtime and size, so the indexed event is used instead of the file.
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(buf), "standup", "sitdown", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+	props := read()
+	if props.Summary != "standup" {
+		t.Errorf("expected the indexed event, got '%s'", props.Summary)
+	}
+	if props.Start.Location().String() != "Europe/Stockholm" {
+		t.Errorf("expected the time zone to be restored, got %s", props.Start.Location())
+	}
+
+	past = past.Add(time.Minute)
+	write("retro")
+	if props := read(); props.Summary != "retro" {
+		t.Errorf("expected the modified event, got '%s'", props.Summary)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, IndexFilename), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if props := read(); props.Summary != "retro" {
+		t.Errorf("expected the corrupt index to be rebuilt, got '%s'", props.Summary)
+	}
+
+	f, err := os.Create(filepath.Join(root, IndexFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := indexFile{Version: IndexVersion - 1, Files: map[string]map[string]indexedFile{
+		"work": {"standup": {ModTime: past, Size: 1, Event: EventProperties{Summary: "old"}}},
+	}}
+	if err := gob.NewEncoder(f).Encode(old); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if props := read(); props.Summary != "retro" {
+		t.Errorf("expected the old index to be rebuilt, got '%s'", props.Summary)
+	}
+}
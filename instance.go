@@ -1,10 +1,12 @@
 package ian
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"time"
@@ -13,19 +15,35 @@ import (
 type Instance struct {
 	Root   string
 	Config Config
+
+	// index is nil if the instance does not use an index (see IndexFilename).
+	index *eventIndex
 }
 
 // Work performs maintenance work and is run on every instance creation.
 // It is used to e.g. update sources.
 func (instance *Instance) Work() error {
-	if err := instance.UpdateSources(); err != nil {
+	// Interrupting (Ctrl-C) cancels the updates.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := instance.UpdateSources(ctx); err != nil {
 		return err
 	}
+	instance.useNativeCalendarConfigs()
+	instance.useSourceCalendarColors()
 	return nil
 }
 
-func (instance *Instance) clearDir(name string) error {
-	return os.RemoveAll(filepath.Join(instance.Root, SanitizeFilepath(name)))
+// getCalendarDir returns the directory of a calendar. Source calendars (see SourceOfCalendar) are in the cache.
+func (instance *Instance) getCalendarDir(calendar string) string {
+	if source, ok := SourceOfCalendar(calendar); ok {
+		if _, sourceCalendar, ok := strings.Cut(calendar, SourceCalendarSeparator); ok {
+			return filepath.Join(instance.getCacheDir(), source, sourceCalendar)
+		}
+		return filepath.Join(instance.getCacheDir(), source)
+	}
+	return filepath.Join(instance.Root, calendar)
 }
 
 // NewEvent constructs a standard event based on properties, as a part of calendar.
@@ -56,6 +74,44 @@ func (instance *Instance) WriteNewEvent(props EventProperties, calendar string)
 	return &event, nil
 }
 
+// NewOverride constructs an override of a generated occurrence of a recurring event, in the calendar of its parent.
+// The override starts out with the properties of the occurrence, and replaces it once written.
+// NewOverride does not write anything.
+func (instance *Instance) NewOverride(occurrence *Event) (Event, error) {
+	if occurrence.Type != EventTypeRecurrence || occurrence.Parent == nil {
+		return Event{}, fmt.Errorf("'%s' is not a generated occurrence", occurrence.Path)
+	}
+
+	props := occurrence.Props
+	props.Recurrence = Recurrence{}
+
+	p, err := NewFreeEventPath(instance, occurrence.Parent.Path.Calendar(), fmt.Sprintf("%s_%s", occurrence.Parent.Path.Name(), props.RecurrenceId.Format("20060102T1504")))
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		Path:   p,
+		Props:  props,
+		Type:   EventTypeNormal,
+		Parent: occurrence.Parent,
+	}, nil
+}
+
+// NewTodo constructs a to-do based on properties, as a part of calendar.
+// NewTodo does not write anything.
+func (instance *Instance) NewTodo(props TodoProperties, calendar string) (Todo, error) {
+	p, err := NewFreeEventPath(instance, calendar, props.FormatName())
+	if err != nil {
+		return Todo{}, err
+	}
+
+	return Todo{
+		Path:  p,
+		Props: props,
+	}, nil
+}
+
 // getAvailableFilename tries to generate an available name like originalName (with possible number suffix), in the directory dir.
 //
 // Note: Only the name is returned, NOT the entire path with dir.
@@ -81,30 +137,45 @@ func (instance *Instance) getAvailableFilename(dir, originalName string) (string
 // ReadEvents reads all events in the instance that appear during the time range, and parses their recurrences.
 // If the time range is empty (From.IsZero() && To.IsZero()), then all events are shown,
 // and recurrences are shown within the range of the normal events.
+// All the events are kept in memory, so use IterateEvents to go through them instead when possible.
 func (instance *Instance) ReadEvents(timeRange TimeRange) ([]Event, []*Event, error) {
+	stored, err := instance.ReadEventFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if timeRange.To.IsZero() {
+		// Recurrences are generated until the end of the latest event (inclusive).
+		timeRange.To = GetEventsTimeRange(stored).To.Add(time.Nanosecond)
+	}
+
 	events := []Event{}
+	it := NewEventIterator(stored, timeRange)
+	for it.Next() {
+		events = append(events, it.Event())
+	}
 
-	calDirs, err := os.ReadDir(instance.Root)
+	return events, it.Unsatisfied(), nil
+}
+
+// ReadEventFiles reads the events of the instance's calendars and sources, without generating their recurrences.
+func (instance *Instance) ReadEventFiles() ([]Event, error) {
+	events := []Event{}
+
+	calendars, err := readCalendarDirs(instance.Root)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	for _, calDir := range calDirs {
-		if strings.HasPrefix(calDir.Name(), ".") {
-			continue
-		}
-		if !calDir.IsDir() {
-			log.Printf("warning: ignoring file '%s'. the root directory should only contain calendars (directories). any other files/directories should be prefixed with a dot ('.').\n", filepath.Join(instance.Root, calDir.Name()))
-			continue
-		}
-		propsList, err := instance.readDir(filepath.Join(instance.Root, calDir.Name()))
+	for _, calendar := range calendars {
+		propsList, _, err := instance.readDir(instance.getCalendarDir(calendar))
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		for name, props := range propsList {
-			path, err := NewEventPath(calDir.Name(), name)
+			path, err := NewEventPath(calendar, name)
 			if err != nil {
-				return nil, nil, err
+				return nil, err
 			}
 			events = append(events, Event{
 				Path:  path,
@@ -116,110 +187,138 @@ func (instance *Instance) ReadEvents(timeRange TimeRange) ([]Event, []*Event, er
 
 	cached, err := instance.ReadCachedEvents()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	events = append(events, cached...)
+	instance.saveIndex()
+
+	return events, nil
+}
 
-	var earliestStart, latestEnd time.Time
+// readDir reads a directory's events and to-dos.
+// The returned maps' keys are the base filenames of the corresponding properties.
+// If the instance has an index, only the files that changed since they were indexed are parsed.
+func (instance *Instance) readDir(dir string) (map[string]EventProperties, map[string]TodoProperties, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	for _, event := range events {
-		if earliestStart.IsZero() || earliestStart.After(event.Props.Start) {
-			earliestStart = event.Props.Start
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+
+		// Ignore dotfiles
+		if strings.HasPrefix(name, ".") {
+			continue
 		}
-		if latestEnd.IsZero() || latestEnd.Before(event.Props.End) {
-			latestEnd = event.Props.End
+		// Subdirectories are nested calendars, which are read by themselves.
+		if entry.IsDir() {
+			continue
 		}
+		names = append(names, name)
 	}
 
-	unsatisfiedRecurrences := []*Event{}
-
-	recurrenceRange := timeRange
-	if recurrenceRange.IsZero() {
-		recurrenceRange.From = earliestStart
-		recurrenceRange.To = latestEnd
+	parse := func(name string) (indexedFile, bool) {
+		return parseCalendarFile(filepath.Join(dir, name))
 	}
-	for _, event := range events {
-		if event.Props.Recurrence.IsThereRecurrence() {
-			rruleSet, err := event.Props.GetRruleSet()
-			if err != nil {
-				log.Printf("warning: '%s' has an invalid recurrence set, and any recurrences were ignored: %s\n", event.Path, err)
-				continue
-			}
-			recurrences := rruleSet.Between(recurrenceRange.From, recurrenceRange.To, true)
-			if len(recurrences) > 0 {
-				recurrences = recurrences[1:] // Ignore the first one because it already exists.
-				for i, recurrence := range recurrences {
-					newProps := event.Props
-					newProps.Start = recurrence
-					newProps.End = newProps.Start.Add(event.Props.End.Sub(event.Props.Start))
-
-					p, err := NewEventPath(
-						event.Path.Calendar(),
-						fmt.Sprintf(".%s_%d", event.Path.Name(), i),
-					)
-					if err != nil {
-						return nil, nil, err
-					}
-
-					events = append(events, Event{
-						Path:     p,
-						Props:    newProps,
-						Type:     EventTypeRecurrence,
-						Constant: true,
-						Parent:   &event,
-					})
-				}
-			}
-			if timeRange.IsZero() && !rruleSet.After(recurrenceRange.To, false).IsZero() {
-				unsatisfiedRecurrences = append(unsatisfiedRecurrences, &event)
+
+	var files map[string]indexedFile
+	if instance.index != nil {
+		files = instance.index.readDir(dir, names, parse)
+	} else {
+		files = map[string]indexedFile{}
+		for _, name := range names {
+			if file, ok := parse(name); ok {
+				files[name] = file
 			}
 		}
 	}
 
-	// Don't delete the events until now, because in the case of a recurring event
-	// that is outside the time range, but has children inside the time range.
-	if !timeRange.IsZero() {
-		events = FilterEvents(&events, func(e *Event) bool {
-			return DoPeriodsMeet(e.Props.GetTimeRange(), timeRange)
-		})
+	eventsProps := map[string]EventProperties{}
+	todosProps := map[string]TodoProperties{}
+
+	for name, file := range files {
+		switch file.Component {
+		case "":
+			eventsProps[name] = file.Event
+		case ComponentTodo:
+			todosProps[name] = file.Todo
+		}
 	}
 
-	return events, unsatisfiedRecurrences, nil
+	return eventsProps, todosProps, nil
 }
 
-// readDir reads a directory's events.
-// The returned map's keys are the base filenames of the corresponding properties.
-func (instance *Instance) readDir(dir string) (map[string]EventProperties, error) {
-	entries, err := os.ReadDir(dir)
+// parseCalendarFile parses the event or to-do file. Files that fail are warned about, and ignored.
+func parseCalendarFile(path string) (indexedFile, bool) {
+	buf, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		log.Printf("warning: '%s' could not be read and was ignored: %s\n", path, err)
+		return indexedFile{}, false
 	}
 
-	eventsProps := map[string]EventProperties{}
-
-	for _, entry := range entries {
-		name := entry.Name()
-		path := filepath.Join(dir, name)
+	component, err := parseComponent(buf)
+	if err != nil {
+		log.Printf("warning: '%s' failed and was ignored: %s\n", path, err)
+		return indexedFile{}, false
+	}
 
-		// Ignore dotfiles
-		if strings.HasPrefix(name, ".") {
-			continue
+	switch component {
+	case "":
+		props, err := parseEvent(buf)
+		if err != nil {
+			log.Printf("warning: event '%s' failed and was ignored: %s\n", path, err)
+			return indexedFile{}, false
 		}
-		if entry.IsDir() {
-			log.Printf("warning: ignoring calendar subdirectory '%s'. consider renaming it to start with a dot ('.'), to ignore it properly.\n", path)
-			continue
+		return indexedFile{Event: props}, true
+	case ComponentTodo:
+		props, err := parseTodo(buf)
+		if err != nil {
+			log.Printf("warning: to-do '%s' failed and was ignored: %s\n", path, err)
+			return indexedFile{}, false
 		}
+		return indexedFile{Component: ComponentTodo, Todo: props}, true
+	default:
+		log.Printf("warning: '%s' has unknown component '%s' and was ignored.\n", path, component)
+		return indexedFile{}, false
+	}
+}
+
+// ReadTodos reads all to-dos in the instance, including those from sources.
+func (instance *Instance) ReadTodos() ([]Todo, error) {
+	todos := []Todo{}
 
-		props, err := parseEventFile(path)
+	calendars, err := readCalendarDirs(instance.Root)
+	if err != nil {
+		return nil, err
+	}
+	for _, calendar := range calendars {
+		_, propsList, err := instance.readDir(instance.getCalendarDir(calendar))
 		if err != nil {
-			log.Printf("warning: event '%s' failed and was ignored: %s\n", path, err)
-			continue
+			return nil, err
+		}
+
+		for name, props := range propsList {
+			path, err := NewEventPath(calendar, name)
+			if err != nil {
+				return nil, err
+			}
+			todos = append(todos, Todo{
+				Path:  path,
+				Props: props,
+			})
 		}
+	}
 
-		eventsProps[name] = props
+	cached, err := instance.ReadCachedTodos()
+	if err != nil {
+		return nil, err
 	}
+	todos = append(todos, cached...)
+	instance.saveIndex()
 
-	return eventsProps, nil
+	return todos, nil
 }
 
 func CreateInstance(root string) (*Instance, error) {
@@ -231,6 +330,7 @@ func CreateInstance(root string) (*Instance, error) {
 	instance := &Instance{
 		Root:   root,
 		Config: config,
+		index:  newEventIndex(root),
 	}
 
 	if err := instance.Work(); err != nil {
@@ -0,0 +1,127 @@
+package ian
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReadEventsWithOverride(t *testing.T) {
+	instance := &Instance{Root: t.TempDir()}
+
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	uid := GenerateUid()
+
+	master := EventProperties{
+		Uid:        uid,
+		Summary:    "standup",
+		Start:      start,
+		End:        start.Add(15 * time.Minute),
+		Recurrence: Recurrence{RRule: "FREQ=DAILY;COUNT=4"},
+	}
+	override := master
+	override.Recurrence = Recurrence{}
+	override.RecurrenceId = start.AddDate(0, 0, 2)
+	override.Start = override.RecurrenceId.Add(2 * time.Hour)
+	override.End = override.Start.Add(time.Hour)
+
+	if err := master.Write(filepath.Join(instance.Root, "work", "standup")); err != nil {
+		t.Fatal(err)
+	}
+	if err := override.Write(filepath.Join(instance.Root, "work", "standup_moved")); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _, err := instance.ReadEvents(TimeRange{From: start, To: start.AddDate(0, 0, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	starts := map[time.Time]EventType{}
+	for _, event := range events {
+		starts[event.Props.Start] = event.Type
+		if event.Props.IsOverride() && (event.Parent == nil || event.Parent.Path.Name() != "standup") {
+			t.Errorf("override '%s' has no parent", event.Path)
+		}
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	if _, ok := starts[override.RecurrenceId]; ok {
+		t.Error("overridden occurrence was still generated")
+	}
+	if typ, ok := starts[override.Start]; !ok || typ != EventTypeNormal {
+		t.Error("override is missing")
+	}
+}
+
+func TestReplacedRecurringEvent(t *testing.T) {
+	instance := &Instance{Root: t.TempDir()}
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	standup := EventProperties{Uid: GenerateUid(), Summary: "standup", Start: start, End: start.Add(15 * time.Minute), Recurrence: Recurrence{RRule: "FREQ=DAILY;COUNT=3"}}
+	moved := standup
+	moved.Recurrence = Recurrence{}
+	moved.RecurrenceId = start
+	moved.Start = start.Add(4 * time.Hour)
+	moved.End = moved.Start.Add(15 * time.Minute)
+	for name, props := range map[string]EventProperties{"standup": standup, "standup_moved": moved} {
+		if err := props.Write(filepath.Join(instance.Root, "work", name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, _, err := instance.ReadEvents(TimeRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pathOrUid := range []string{"work/standup", standup.Uid} {
+		event, err := FindEvent(&events, pathOrUid)
+		if err != nil {
+			t.Fatalf("expected to find the recurring event by '%s': %s", pathOrUid, err)
+		}
+		if !event.Replaced || event.Path.String() != "work/standup" {
+			t.Errorf("expected '%s' to be the replaced recurring event, got '%s'", pathOrUid, event.Path)
+		}
+	}
+
+	occurring, _, err := instance.ReadEvents(TimeRange{From: start, To: start.Add(72 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	starts := []string{}
+	for _, e := range FilterOccurring(occurring) {
+		starts = append(starts, e.Props.Start.Format("2T15"))
+	}
+	slices.Sort(starts)
+	if expected := []string{"6T13", "7T09", "8T09"}; !slices.Equal(starts, expected) {
+		t.Errorf("expected only the override to replace the first occurrence: %v, got %v", expected, starts)
+	}
+	for _, period := range GetBusyPeriods(occurring, TimeRange{From: start, To: start.Add(time.Hour)}) {
+		t.Errorf("expected the replaced occurrence not to be busy, got %v", period)
+	}
+
+	// Edit and delete it by its path.
+	event, _ := GetEvent(&events, "work/standup")
+	event.Props.Summary = "daily"
+	if err := event.Write(instance); err != nil {
+		t.Fatal(err)
+	}
+	if events, _, err = instance.ReadEvents(TimeRange{}); err != nil {
+		t.Fatal(err)
+	}
+	if event, err = GetEvent(&events, "work/standup"); err != nil || event.Props.Summary != "daily" {
+		t.Fatalf("expected the edit to be kept, got %v (%v)", event, err)
+	}
+	if err := event.Delete(instance); err != nil {
+		t.Fatal(err)
+	}
+	if events, _, err = instance.ReadEvents(TimeRange{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetEvent(&events, "work/standup"); err == nil {
+		t.Error("expected the recurring event to be deleted")
+	}
+}
@@ -0,0 +1,353 @@
+package ian
+
+import (
+	"container/heap"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// EventIterator yields the events that appear during a time range, ordered by their start.
+// The occurrences of recurring events are generated as the iteration reaches them, so a recurrence is never expanded
+// further than what has been read, and the iteration can be stopped (or paged through) at any time.
+//
+//	it := NewEventIterator(events, timeRange)
+//	for it.Next() {
+//		event := it.Event()
+//	}
+type EventIterator struct {
+	timeRange TimeRange
+	queue     eventQueue
+	event     Event
+
+	unsatisfied []*Event
+}
+
+// queuedEvent is an event, or the next occurrence of a recurring event, that has not been yielded yet.
+type queuedEvent struct {
+	event Event
+	// series is set if the event is an occurrence, to generate the next one.
+	series *eventSeries
+}
+
+// eventSeries generates the occurrences of a recurring event.
+type eventSeries struct {
+	master     *Event
+	next       func() (time.Time, bool)
+	duration   time.Duration
+	overridden func(t time.Time) bool
+}
+
+type eventQueue []*queuedEvent
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if !q[i].event.Props.Start.Equal(q[j].event.Props.Start) {
+		return q[i].event.Props.Start.Before(q[j].event.Props.Start)
+	}
+	return q[i].event.Path.String() < q[j].event.Path.String()
+}
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x any)   { *q = append(*q, x.(*queuedEvent)) }
+func (q *eventQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// NewEventIterator returns an iterator over the events (as read by ReadEventFiles) and the occurrences of the recurring
+// ones, that appear during the time range. Recurring events whose own occurrence is overridden are yielded too, as
+// Replaced (see FilterOccurring).
+// A zero From or To leaves that end of the time range open. Note that iterating to the end of a time range without an
+// end never stops if there is an infinite recurrence.
+func NewEventIterator(events []Event, timeRange TimeRange) *EventIterator {
+	it := &EventIterator{timeRange: timeRange}
+
+	// The events are copied, so that occurrences and overrides can point to their (master) recurring event.
+	stored := make([]Event, len(events))
+	copy(stored, events)
+
+	// Overrides replace single occurrences of the recurring event with the same UID in the same calendar.
+	// They are keyed by calendar and UID.
+	overrides := map[[2]string][]*Event{}
+	for i := range stored {
+		if stored[i].Props.IsOverride() {
+			key := [2]string{stored[i].Path.Calendar(), stored[i].Props.Uid}
+			overrides[key] = append(overrides[key], &stored[i])
+		}
+	}
+
+	series := []*eventSeries{}
+
+	for i := range stored {
+		master := &stored[i]
+		if !master.Props.Recurrence.IsThereRecurrence() || master.Props.IsOverride() {
+			continue
+		}
+		rruleSet, err := master.Props.GetRruleSet()
+		if err != nil {
+			log.Printf("warning: '%s' has an invalid recurrence set, and any recurrences were ignored: %s\n", master.Path, err)
+			continue
+		}
+
+		masterOverrides := overrides[[2]string{master.Path.Calendar(), master.Props.Uid}]
+		isOverridden := func(t time.Time) bool {
+			for _, override := range masterOverrides {
+				if override.Props.RecurrenceId.Equal(t) {
+					return true
+				}
+			}
+			return false
+		}
+		for _, override := range masterOverrides {
+			override.Parent = master
+		}
+		// The recurring event is kept, but its own occurrence is the override's.
+		master.Replaced = isOverridden(master.Props.Start)
+
+		if !timeRange.From.IsZero() {
+			// The occurrences that end before the time range are not generated.
+			rruleSet = seekRruleSet(rruleSet, timeRange.From.Add(-master.Props.End.Sub(master.Props.Start)))
+		}
+
+		series = append(series, &eventSeries{
+			master:     master,
+			next:       rruleSet.Iterator(),
+			duration:   master.Props.End.Sub(master.Props.Start),
+			overridden: isOverridden,
+		})
+	}
+
+	for i := range stored {
+		if it.meets(stored[i].Props.GetTimeRange()) {
+			it.queue = append(it.queue, &queuedEvent{event: stored[i]})
+		}
+	}
+	for _, s := range series {
+		if occurrence, ok := it.advance(s); ok {
+			it.queue = append(it.queue, &queuedEvent{event: occurrence, series: s})
+		}
+	}
+	heap.Init(&it.queue)
+
+	return it
+}
+
+// meets returns true if the period meets the time range of the iterator.
+func (it *EventIterator) meets(period TimeRange) bool {
+	timeRange := it.timeRange
+	if timeRange.To.IsZero() {
+		timeRange.To = maxTime
+	}
+	if period.To.Before(period.From) {
+		// An invalid event is not worth a panic.
+		period.To = period.From
+	}
+	return DoPeriodsMeet(period, timeRange)
+}
+
+// maxTime is the end of time ranges without an end.
+var maxTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// advance generates the next occurrence of the series that appears during the time range.
+func (it *EventIterator) advance(s *eventSeries) (Event, bool) {
+	for {
+		t, ok := s.next()
+		if !ok {
+			return Event{}, false
+		}
+		// The first occurrence is the recurring event itself.
+		if t.Equal(s.master.Props.Start) {
+			continue
+		}
+		if s.overridden(t) {
+			continue
+		}
+
+		if !it.timeRange.To.IsZero() && !t.Before(it.timeRange.To) {
+			it.unsatisfied = append(it.unsatisfied, s.master)
+			return Event{}, false
+		}
+		if !it.meets(TimeRange{From: t, To: t.Add(s.duration)}) {
+			// Before the time range.
+			continue
+		}
+
+		props := s.master.Props
+		props.Start = t
+		props.End = t.Add(s.duration)
+		props.RecurrenceId = t
+
+		// The occurrences are named by their time, so that their paths are the same regardless of the time range.
+		path, err := NewEventPath(s.master.Path.Calendar(), fmt.Sprintf(".%s_%s", s.master.Path.Name(), t.In(time.UTC).Format("20060102T150405Z")))
+		if err != nil {
+			log.Printf("warning: the recurrences of '%s' were ignored: %s\n", s.master.Path, err)
+			return Event{}, false
+		}
+
+		return Event{
+			Path:     path,
+			Props:    props,
+			Type:     EventTypeRecurrence,
+			Constant: true,
+			Parent:   s.master,
+		}, true
+	}
+}
+
+// seekRruleSet returns the recurrence set with its RRULE starting at the period (e.g. the day, or the week) that t is in,
+// instead of at the start of the recurring event. The occurrences before the period are left out, so that an old
+// recurrence is not walked through from its start to reach t.
+// The rule's parts that default to those of its start (like the time of day) are set explicitly, so the occurrences
+// are otherwise the same. A rule that cannot be started later (it has a COUNT, or recurs more often than daily) is
+// returned as it is.
+func seekRruleSet(set rrule.Set, t time.Time) rrule.Set {
+	r := set.GetRRule()
+	if r == nil {
+		return set
+	}
+	opts := r.OrigOptions
+	start := opts.Dtstart
+	interval := max(opts.Interval, 1)
+	if opts.Count != 0 || opts.Freq > rrule.DAILY || !t.After(start) {
+		return set
+	}
+	t = t.In(start.Location())
+
+	// Like rrule.NewRRule defaults them.
+	if len(opts.Byweekno) == 0 && len(opts.Byyearday) == 0 && len(opts.Bymonthday) == 0 && len(opts.Byweekday) == 0 && len(opts.Byeaster) == 0 {
+		switch opts.Freq {
+		case rrule.YEARLY:
+			if len(opts.Bymonth) == 0 {
+				opts.Bymonth = []int{int(start.Month())}
+			}
+			opts.Bymonthday = []int{start.Day()}
+		case rrule.MONTHLY:
+			opts.Bymonthday = []int{start.Day()}
+		case rrule.WEEKLY:
+			opts.Byweekday = []rrule.Weekday{rruleWeekdays[start.Weekday()]}
+		}
+	}
+	if len(opts.Byhour) == 0 {
+		opts.Byhour = []int{start.Hour()}
+	}
+	if len(opts.Byminute) == 0 {
+		opts.Byminute = []int{start.Minute()}
+	}
+	if len(opts.Bysecond) == 0 {
+		opts.Bysecond = []int{start.Second()}
+	}
+
+	// The start of the last period of the rule that begins before t.
+	y, m, d := start.Date()
+	switch opts.Freq {
+	case rrule.YEARLY:
+		y += (t.Year() - y) / interval * interval
+		m, d = time.January, 1
+	case rrule.MONTHLY:
+		months := (t.Year()-y)*12 + int(t.Month()-m)
+		m += time.Month(months / interval * interval)
+		d = 1
+	case rrule.WEEKLY, rrule.DAILY:
+		periodDays := interval
+		if opts.Freq == rrule.WEEKLY {
+			periodDays *= 7
+			// Weeks start on WKST.
+			d -= (int(start.Weekday()) - (opts.Wkst.Day()+1)%7 + 7) % 7
+		}
+		days := int(civilDate(t).Sub(civilDate(time.Date(y, m, d, 0, 0, 0, 0, time.UTC))).Hours() / 24)
+		d += days / periodDays * periodDays
+	}
+	opts.Dtstart = time.Date(y, m, d, 0, 0, 0, 0, start.Location())
+	if !opts.Dtstart.After(start) {
+		// Still in the first period, where the occurrences before the start are left out.
+		return set
+	}
+
+	seeked, err := rrule.NewRRule(opts)
+	if err != nil {
+		return set
+	}
+	var result rrule.Set
+	result.RRule(seeked)
+	result.SetRDates(set.GetRDate())
+	result.SetExDates(set.GetExDate())
+	return result
+}
+
+// rruleWeekdays are the weekdays of rrule by time.Weekday.
+var rruleWeekdays = []rrule.Weekday{rrule.SU, rrule.MO, rrule.TU, rrule.WE, rrule.TH, rrule.FR, rrule.SA}
+
+// civilDate returns the date of t at midnight UTC, so that days can be counted without time zone changes.
+func civilDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Next advances the iterator to the next event, which is then returned by Event.
+// It returns false when there are no more events.
+func (it *EventIterator) Next() bool {
+	if len(it.queue) == 0 {
+		return false
+	}
+	item := it.queue[0]
+	it.event = item.event
+
+	if item.series == nil {
+		heap.Pop(&it.queue)
+		return true
+	}
+	if occurrence, ok := it.advance(item.series); ok {
+		item.event = occurrence
+		heap.Fix(&it.queue, 0)
+	} else {
+		heap.Pop(&it.queue)
+	}
+	return true
+}
+
+// Event returns the current event of the iterator.
+func (it *EventIterator) Event() Event {
+	return it.event
+}
+
+// Page returns the next n events, or fewer if the iterator runs out of events.
+func (it *EventIterator) Page(n int) []Event {
+	events := []Event{}
+	for len(events) < n && it.Next() {
+		events = append(events, it.Event())
+	}
+	return events
+}
+
+// Unsatisfied returns the recurring events that the iteration has found to continue after the end of the time range.
+func (it *EventIterator) Unsatisfied() []*Event {
+	return it.unsatisfied
+}
+
+// IterateEvents returns an iterator over the events of the instance (including those from sources) that appear during
+// the time range. See NewEventIterator.
+func (instance *Instance) IterateEvents(timeRange TimeRange) (*EventIterator, error) {
+	events, err := instance.ReadEventFiles()
+	if err != nil {
+		return nil, err
+	}
+	return NewEventIterator(events, timeRange), nil
+}
+
+// GetEventsTimeRange returns the time range from the earliest start to the latest end of the events.
+func GetEventsTimeRange(events []Event) TimeRange {
+	var timeRange TimeRange
+	for _, event := range events {
+		if timeRange.From.IsZero() || timeRange.From.After(event.Props.Start) {
+			timeRange.From = event.Props.Start
+		}
+		if timeRange.To.IsZero() || timeRange.To.Before(event.Props.End) {
+			timeRange.To = event.Props.End
+		}
+	}
+	return timeRange
+}
@@ -0,0 +1,133 @@
+package ian
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEventIterator(t *testing.T) {
+	event := func(name string, start time.Time, d time.Duration, rrule string) Event {
+		path, err := NewEventPath("work", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Event{
+			Path: path,
+			Props: EventProperties{
+				Uid:        name,
+				Summary:    name,
+				Start:      start,
+				End:        start.Add(d),
+				Recurrence: Recurrence{RRule: rrule},
+			},
+			Type: EventTypeNormal,
+		}
+	}
+	day := func(d int, hour int) time.Time {
+		return time.Date(2024, 5, d, hour, 0, 0, 0, time.UTC)
+	}
+
+	// Daily since 2000, and never ending.
+	standup := event("standup", time.Date(2000, 1, 1, 9, 0, 0, 0, time.UTC), 15*time.Minute, "FREQ=DAILY")
+	// Overlaps the start of the time range.
+	night := event("night", day(5, 22), 10*time.Hour, "FREQ=WEEKLY")
+	moved := standup
+	moved.Path, _ = NewEventPath("work", "standup_moved")
+	moved.Props.Recurrence = Recurrence{}
+	moved.Props.RecurrenceId = day(7, 9)
+	moved.Props.Start = day(7, 13)
+	moved.Props.End = day(7, 14)
+	events := []Event{standup, night, moved, event("lunch", day(6, 12), time.Hour, ""), event("old", day(1, 12), time.Hour, "")}
+
+	it := NewEventIterator(events, TimeRange{From: day(6, 0), To: day(8, 0)})
+	names := []string{}
+	for it.Next() {
+		e := it.Event()
+		names = append(names, e.Props.Summary+"@"+e.Props.Start.Format("2T15"))
+		if e.Type == EventTypeRecurrence && e.Parent == nil {
+			t.Errorf("occurrence '%s' has no parent", e.Path)
+		}
+		if e.Props.IsOverride() && (e.Parent == nil || e.Parent.Path.Name() != "standup") {
+			t.Errorf("override '%s' has no parent", e.Path)
+		}
+	}
+	expected := []string{"night@5T22", "standup@6T09", "lunch@6T12", "standup@7T13"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+	unsatisfied := []string{}
+	for _, e := range it.Unsatisfied() {
+		unsatisfied = append(unsatisfied, e.Path.Name())
+	}
+	slices.Sort(unsatisfied)
+	if !slices.Equal(unsatisfied, []string{"night", "standup"}) {
+		t.Errorf("expected both recurrences to continue after the range, got %v", unsatisfied)
+	}
+
+	// Without an end, the infinite recurrence is paged through.
+	it = NewEventIterator([]Event{standup}, TimeRange{From: day(6, 0)})
+	first, second := it.Page(2), it.Page(2)
+	if len(first) != 2 || len(second) != 2 || !first[0].Props.Start.Equal(day(6, 9)) || !second[1].Props.Start.Equal(day(9, 9)) {
+		t.Errorf("unexpected pages: %v, %v", first, second)
+	}
+	if first[1].Path.Name() == second[0].Path.Name() {
+		t.Errorf("expected the occurrences to have different paths, got '%s' twice", first[1].Path.Name())
+	}
+}
+
+func TestSeekRruleSet(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2024, 5, 6, 9, 30, 0, 0, loc)
+	to := from.AddDate(4, 1, 0)
+
+	for _, c := range []struct {
+		rrule string
+		start time.Time
+	}{
+		{"FREQ=DAILY", time.Date(2000, 1, 1, 9, 0, 0, 0, loc)},
+		{"FREQ=DAILY;INTERVAL=3;BYHOUR=8,20;UNTIL=20250101T000000Z", time.Date(2005, 6, 1, 20, 0, 0, 0, loc)},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", time.Date(2001, 3, 7, 17, 0, 0, 0, loc)},
+		{"FREQ=WEEKLY;INTERVAL=3;WKST=SU", time.Date(2010, 1, 3, 7, 15, 0, 0, loc)},
+		{"FREQ=MONTHLY", time.Date(2003, 1, 31, 12, 0, 0, 0, loc)},
+		{"FREQ=MONTHLY;INTERVAL=5;BYDAY=-1FR", time.Date(2002, 2, 1, 10, 0, 0, 0, loc)},
+		{"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", time.Date(2000, 1, 31, 16, 0, 0, 0, loc)},
+		{"FREQ=YEARLY", time.Date(2000, 2, 29, 0, 0, 0, 0, loc)},
+		{"FREQ=YEARLY;INTERVAL=3;BYWEEKNO=20;BYDAY=TU", time.Date(2001, 5, 15, 9, 0, 0, 0, loc)},
+		{"FREQ=DAILY;COUNT=10000", time.Date(2000, 1, 1, 9, 0, 0, 0, loc)},
+		{"FREQ=WEEKLY;BYDAY=MO,WE", time.Date(2024, 5, 1, 9, 0, 0, 0, loc)},
+	} {
+		props := EventProperties{Start: c.start, End: c.start.Add(time.Hour), TimeZone: loc.String(), Recurrence: Recurrence{RRule: c.rrule}}
+		set, err := props.GetRruleSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seeked := seekRruleSet(set, from)
+		expected := set.Between(from, to, true)
+		if len(expected) == 0 {
+			t.Fatalf("%s: expected occurrences during the time range", c.rrule)
+		}
+		if got := seeked.Between(from, to, true); !slices.Equal(got, expected) {
+			t.Errorf("%s: expected %v, got %v", c.rrule, expected, got)
+		}
+	}
+
+	// An old daily recurrence reaches the time range at once.
+	props := EventProperties{Start: time.Date(2000, 1, 1, 9, 0, 0, 0, loc), TimeZone: loc.String(), Recurrence: Recurrence{RRule: "FREQ=DAILY"}}
+	set, err := props.GetRruleSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeked := seekRruleSet(set, from)
+	next := seeked.Iterator()
+	steps := 0
+	for occurrence, ok := next(); ok && occurrence.Before(from); occurrence, ok = next() {
+		steps++
+	}
+	if steps > 1 {
+		t.Errorf("expected at most 1 occurrence before the time range, got %d", steps)
+	}
+}
@@ -0,0 +1,154 @@
+package ian
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// localPath returns the path of a "file" or "vdir" source.
+func (source *CalendarSource) localPath() string {
+	return strings.TrimPrefix(source.Source, "file://")
+}
+
+// localModTime returns when the file, or the directory or any of its files, was last modified.
+func (source *CalendarSource) localModTime() (time.Time, error) {
+	info, err := os.Stat(source.localPath())
+	if err != nil {
+		return time.Time{}, err
+	}
+	modTime := info.ModTime()
+	if source.Type != "vdir" {
+		return modTime, nil
+	}
+
+	entries, err := os.ReadDir(source.localPath())
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+// isModified returns true if the source is a "file" or "vdir" that has been modified since the update of journalSource.
+func (source *CalendarSource) isModified(journalSource CacheJournalSource) bool {
+	if source.Type != "file" && source.Type != "vdir" {
+		return false
+	}
+	modTime, err := source.localModTime()
+	if err != nil {
+		// The update reports the error when the lifetime expires.
+		return false
+	}
+	return !modTime.Equal(journalSource.ModTime)
+}
+
+// importFile reads the source's iCalendar file.
+func (source *CalendarSource) importFile(name string) (*ical.Calendar, error) {
+	if Verbose {
+		log.Printf("reading iCalendar file '%s'\n", source.localPath())
+	}
+	f, err := os.Open(source.localPath())
+	if err != nil {
+		return nil, fmt.Errorf("source '%s': %s", name, err)
+	}
+	defer f.Close()
+	return ParseIcal(f)
+}
+
+// importVdir reads the source's vdir (a directory with an iCalendar file for each event or to-do, like vdirsyncer's), merged into one calendar.
+func (source *CalendarSource) importVdir(name string) (*ical.Calendar, error) {
+	dir := source.localPath()
+	if Verbose {
+		log.Printf("reading vdir '%s'\n", dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("source '%s': %s", name, err)
+	}
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//ian//ian vdir source")
+	timeZones := map[string]bool{}
+
+	for _, entry := range entries {
+		// Temporary files of vdirsyncer and others are hidden.
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || filepath.Ext(entry.Name()) != ".ics" {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		item, err := ParseIcal(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("source '%s': '%s': %s", name, entry.Name(), err)
+		}
+
+		for _, child := range item.Children {
+			if child.Name == ical.CompTimezone {
+				// Every item carries the time zones it uses, so skip duplicates.
+				tzid, _ := child.Props.Text(ical.PropTimezoneID)
+				if timeZones[tzid] {
+					continue
+				}
+				timeZones[tzid] = true
+			}
+			cal.Children = append(cal.Children, child)
+		}
+	}
+
+	return cal, nil
+}
+
+// importCommand runs the source's shell command, and parses its output as iCalendar.
+func (source *CalendarSource) importCommand(ctx context.Context, name string) (*ical.Calendar, error) {
+	if Verbose {
+		log.Printf("running command '%s' of source '%s'\n", source.Source, name)
+	}
+
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.CommandContext(ctx, source.Source)
+	default:
+		cmd = exec.CommandContext(ctx, "sh", "-c", source.Source)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("source '%s' command failed (%s): %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("source '%s' command failed: %s", name, err)
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, errors.New("source '" + name + "' command printed no calendar")
+	}
+
+	return ParseIcal(bytes.NewReader(out))
+}
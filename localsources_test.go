@@ -0,0 +1,118 @@
+package ian
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const localSourceEvent = "BEGIN:VEVENT\r\nUID:%s\r\nDTSTAMP:20240506T090000Z\r\nDTSTART;TZID=Europe/Stockholm:20240506T090000\r\nDTEND;TZID=Europe/Stockholm:20240506T100000\r\nSUMMARY:%s\r\nEND:VEVENT\r\n"
+const localSourceTimeZone = "BEGIN:VTIMEZONE\r\nTZID:Europe/Stockholm\r\nBEGIN:STANDARD\r\nDTSTART:19701025T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n"
+
+func localSourceCalendar(events ...string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\n" + localSourceTimeZone + strings.Join(events, "") + "END:VCALENDAR\r\n"
+}
+
+func cachedSummaries(t *testing.T, instance *Instance) []string {
+	events, err := instance.ReadCachedEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	summaries := []string{}
+	for _, event := range events {
+		summaries = append(summaries, event.Props.Summary)
+	}
+	slices.Sort(summaries)
+	return summaries
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.ics")
+	write := func(summary string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(localSourceCalendar(fmt.Sprintf(localSourceEvent, "1", summary))), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("lecture", time.Now().Add(-time.Hour))
+
+	instance := &Instance{Root: t.TempDir()}
+	instance.Config.Sources = map[string]CalendarSource{
+		"school": {Source: "file://" + path, Type: "file"},
+	}
+
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s := cachedSummaries(t, instance); !slices.Equal(s, []string{"lecture"}) {
+		t.Errorf("expected the file to be cached, got %v", s)
+	}
+
+	// Modified before the lifetime has expired.
+	write("exam", time.Now())
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s := cachedSummaries(t, instance); !slices.Equal(s, []string{"exam"}) {
+		t.Errorf("expected the modified file to be cached, got %v", s)
+	}
+}
+
+func TestVdirSource(t *testing.T) {
+	dir := t.TempDir()
+	for uid, summary := range map[string]string{"1": "lecture", "2": "exam"} {
+		if err := os.WriteFile(filepath.Join(dir, uid+".ics"), []byte(localSourceCalendar(fmt.Sprintf(localSourceEvent, uid, summary))), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Not an item.
+	if err := os.WriteFile(filepath.Join(dir, ".1.ics.tmp"), []byte("BROKEN"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := CalendarSource{Source: dir, Type: "vdir"}
+	cal, err := source.Import("school")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cal.Children) != 3 {
+		t.Errorf("expected 2 events and 1 time zone, got %d components", len(cal.Children))
+	}
+
+	instance := &Instance{Root: t.TempDir()}
+	instance.Config.Sources = map[string]CalendarSource{"school": source}
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s := cachedSummaries(t, instance); !slices.Equal(s, []string{"exam", "lecture"}) {
+		t.Errorf("expected the vdir to be cached, got %v", s)
+	}
+}
+
+func TestCommandSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tickets.ics")
+	if err := os.WriteFile(path, []byte(localSourceCalendar(fmt.Sprintf(localSourceEvent, "1", "release"))), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := CalendarSource{Source: "cat '" + path + "'", Type: "command"}
+	cal, err := source.Import("tickets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cal.Events()) != 1 {
+		t.Errorf("expected 1 event, got %d", len(cal.Events()))
+	}
+
+	source.Source = "echo 'no tickets' >&2; exit 1"
+	if _, err := source.Import("tickets"); err == nil {
+		t.Error("expected the failing command to fail")
+	}
+}
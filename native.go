@@ -0,0 +1,215 @@
+package ian
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// NativeExportPath is the path of the native server's export endpoint, which native sources fetch calendars from.
+const NativeExportPath string = "/export"
+
+// NativeCalendarsFileName is the file in a native source's cache directory with the configurations of its calendars.
+const NativeCalendarsFileName string = ".calendars.toml"
+
+// SourceCalendarSeparator separates the source and calendar names of a calendar in a native source (e.g. '.team:work').
+const SourceCalendarSeparator string = ":"
+
+// NativeCalendar is a calendar of an instance, as exported to native sources.
+type NativeCalendar struct {
+	Config CalendarConfig
+	// Events and Todos are keyed by their filenames.
+	Events map[string]EventProperties
+	Todos  map[string]TodoProperties
+}
+
+// ExportCalendars reads the instance's own calendars (not its sources), with their configurations.
+func (instance *Instance) ExportCalendars() (map[string]NativeCalendar, error) {
+	calendars := map[string]NativeCalendar{}
+
+	calendarNames, err := readCalendarDirs(instance.Root)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range calendarNames {
+		eventsProps, todosProps, err := instance.readDir(instance.getCalendarDir(name))
+		if err != nil {
+			return nil, err
+		}
+
+		calendar := NativeCalendar{
+			Events: eventsProps,
+			Todos:  todosProps,
+		}
+		if conf, err := instance.Config.GetContainerConfig(name); err == nil {
+			calendar.Config = *conf
+		}
+		calendars[name] = calendar
+	}
+
+	return calendars, nil
+}
+
+// importNative reads the calendars of the source's ian root, or fetches them from the source's ian server.
+func (source *CalendarSource) importNative(ctx context.Context, name string) (map[string]NativeCalendar, error) {
+	if !strings.HasPrefix(source.Source, "http://") && !strings.HasPrefix(source.Source, "https://") {
+		root := strings.TrimPrefix(source.Source, "file://")
+		if Verbose {
+			log.Printf("reading ian root '%s'\n", root)
+		}
+		config, err := ReadConfig(root)
+		if err != nil {
+			return nil, fmt.Errorf("source '%s': %s", name, err)
+		}
+		// The root is only read, so its own sources are not updated.
+		remote := &Instance{
+			Root:   root,
+			Config: config,
+		}
+		return remote.ExportCalendars()
+	}
+
+	url := strings.TrimSuffix(source.Source, "/") + NativeExportPath
+	if Verbose {
+		log.Printf("downloading calendars from ian server '%s'\n", url)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client, err := source.httpClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("source '%s': %s", name, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("source '%s' HTTP request failed: %s", name, resp.Status)
+	}
+
+	var calendars map[string]NativeCalendar
+	if err := json.NewDecoder(resp.Body).Decode(&calendars); err != nil {
+		return nil, fmt.Errorf("source '%s' returned invalid calendars: %s", name, err)
+	}
+	return calendars, nil
+}
+
+// applyNativeRules applies the rules of the source to the events of each calendar, and counts the dropped events of all calendars.
+func (source *CalendarSource) applyNativeRules(calendars map[string]NativeCalendar, now time.Time) (map[string]int, error) {
+	if !source.HasRules() {
+		return nil, nil
+	}
+	rules, err := source.compileRules(now)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped := map[string]int{}
+	for _, native := range calendars {
+		for filename, props := range native.Events {
+			rule, err := rules.apply(&props)
+			if err != nil {
+				return nil, err
+			}
+			if rule != "" {
+				dropped[rule]++
+				delete(native.Events, filename)
+				continue
+			}
+			native.Events[filename] = props
+		}
+	}
+	return dropped, nil
+}
+
+// cacheNativeCalendars caches each calendar of a native source in its own directory, with its events' original names.
+func (instance *Instance) cacheNativeCalendars(name string, calendars map[string]NativeCalendar) error {
+	return instance.replaceCache(name, func(subDir string) error {
+		return instance.cacheNativeCalendarsIn(filepath.Join(instance.getCacheDir(), subDir), name, calendars)
+	})
+}
+
+func (instance *Instance) cacheNativeCalendarsIn(dir, name string, calendars map[string]NativeCalendar) error {
+	configs := map[string]CalendarConfig{}
+
+	for calendar, native := range calendars {
+		// The names come from elsewhere, so make sure they stay inside the cache.
+		if strings.HasPrefix(calendar, ".") || strings.Contains(calendar, SourceCalendarSeparator) || !isValidCalendarName(calendar) {
+			log.Printf("warning: ignored calendar '%s' in source '%s'\n", calendar, name)
+			continue
+		}
+		cacheCalendar := "." + name + SourceCalendarSeparator + calendar
+		calendarDir := filepath.Join(dir, calendar)
+
+		for filename, props := range native.Events {
+			if _, err := NewEventPath(cacheCalendar, filename); err != nil || strings.HasPrefix(filename, ".") {
+				log.Printf("warning: ignored event '%s' in calendar '%s' of source '%s'\n", filename, calendar, name)
+				continue
+			}
+			if err := props.Write(filepath.Join(calendarDir, filename)); err != nil {
+				return err
+			}
+		}
+		for filename, props := range native.Todos {
+			if _, err := NewEventPath(cacheCalendar, filename); err != nil || strings.HasPrefix(filename, ".") {
+				log.Printf("warning: ignored to-do '%s' in calendar '%s' of source '%s'\n", filename, calendar, name)
+				continue
+			}
+			if err := props.Write(filepath.Join(calendarDir, filename)); err != nil {
+				return err
+			}
+		}
+		// Empty calendars are kept too.
+		if err := CreateDir(calendarDir); err != nil {
+			return err
+		}
+
+		configs[calendar] = native.Config
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("# This file is automatically generated and managed.\n\n")
+	if err := toml.NewEncoder(buf).Encode(configs); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, NativeCalendarsFileName), buf.Bytes(), 0644)
+}
+
+// useNativeCalendarConfigs configures the cached calendars of native sources like in their source, unless they are configured locally.
+func (instance *Instance) useNativeCalendarConfigs() {
+	for name, source := range instance.Config.Sources {
+		if source.Type != "native" {
+			continue
+		}
+
+		var configs map[string]CalendarConfig
+		if _, err := toml.DecodeFile(filepath.Join(instance.getCacheDir(), name, NativeCalendarsFileName), &configs); err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("warning: failed to read calendar configurations of source '%s': %s\n", name, err)
+			}
+			continue
+		}
+
+		if instance.Config.Calendars == nil {
+			instance.Config.Calendars = map[string]CalendarConfig{}
+		}
+		for calendar, config := range configs {
+			cacheCalendar := "." + name + SourceCalendarSeparator + calendar
+			if _, ok := instance.Config.Calendars[cacheCalendar]; !ok {
+				instance.Config.Calendars[cacheCalendar] = config
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package ian
+
+import (
+	"context"
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNativeSource(t *testing.T) {
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	remote := &Instance{Root: t.TempDir()}
+	config := "[calendars.work]\n  color = { r = 153, g = 90, b = 209 }\n"
+	if err := os.WriteFile(filepath.Join(remote.Root, ConfigFilename), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if remote.Config, err = ReadConfig(remote.Root); err != nil {
+		t.Fatal(err)
+	}
+
+	props := EventProperties{
+		Uid:     GenerateUid(),
+		Summary: "retro",
+		Start:   start,
+		End:     start.Add(time.Hour),
+	}
+	if err := props.Write(filepath.Join(remote.Root, "work", "sprint retro")); err != nil {
+		t.Fatal(err)
+	}
+	props.Uid = GenerateUid()
+	props.Summary = "offsite"
+	if err := props.Write(filepath.Join(remote.Root, "away", "offsite")); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != NativeExportPath {
+			http.NotFound(w, r)
+			return
+		}
+		calendars, err := remote.ExportCalendars()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(calendars)
+	}))
+	defer server.Close()
+
+	for _, source := range []CalendarSource{
+		{Source: remote.Root, Type: "native"},
+		{Source: server.URL, Type: "native"},
+	} {
+		instance := &Instance{Root: t.TempDir()}
+		instance.Config.Sources = map[string]CalendarSource{"team": source}
+
+		if err := source.ImportAndUse(context.Background(), instance, "team"); err != nil {
+			t.Fatal(err)
+		}
+		instance.useNativeCalendarConfigs()
+
+		events, _, err := instance.ReadEvents(TimeRange{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		paths := map[string]bool{}
+		for _, event := range events {
+			paths[event.Path.String()] = true
+			if !event.Constant {
+				t.Errorf("'%s' from a native source is not constant", event.Path)
+			}
+		}
+		if len(events) != 2 || !paths[".team:work/sprint retro"] || !paths[".team:away/offsite"] {
+			t.Errorf("%s: expected the events with their calendars and names, got %v", source.Source, paths)
+		}
+
+		conf, err := instance.Config.GetContainerConfig(".team:work")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conf.Color != (color.RGBA{R: 153, G: 90, B: 209}) {
+			t.Errorf("%s: expected the calendar color from the source, got %v", source.Source, conf.Color)
+		}
+	}
+}
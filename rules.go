@@ -0,0 +1,250 @@
+package ian
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// SourceFilterFields are the fields of events that filters can match.
+var SourceFilterFields = []string{"summary", "location", "categories"}
+
+// SourceFilter matches the events of a source whose field matches the regular expression.
+type SourceFilter struct {
+	// Field is one of SourceFilterFields. An event matches "categories" if any of its categories matches.
+	Field   string
+	Pattern string
+}
+
+func (filter SourceFilter) String() string {
+	return fmt.Sprintf("%s '%s'", filter.Field, filter.Pattern)
+}
+
+func (filter SourceFilter) match(pattern *regexp.Regexp, props *EventProperties) bool {
+	switch filter.Field {
+	case "summary":
+		return pattern.MatchString(props.Summary)
+	case "location":
+		return pattern.MatchString(props.Location)
+	case "categories":
+		return slices.ContainsFunc(props.Categories, pattern.MatchString)
+	}
+	return false
+}
+
+// SourceRewrite replaces the matches of the regular expression in the summaries of a source's events.
+type SourceRewrite struct {
+	Pattern string
+	// Replacement can refer to submatches, like regexp.Regexp.ReplaceAllString (e.g. '$1').
+	Replacement string
+}
+
+// HasRules returns true if the source filters or transforms its events.
+func (source *CalendarSource) HasRules() bool {
+	return len(source.Include) != 0 || len(source.Exclude) != 0 || len(source.Rewrite) != 0 || source.Shift != "" || source.Cutoff != ""
+}
+
+// rulesHash returns a hash of the source's rules, which tells whether the cache was written with other rules.
+// It is empty if the source has no rules.
+func (source *CalendarSource) rulesHash() string {
+	if !source.HasRules() {
+		return ""
+	}
+	rules := fmt.Sprintf("%q\n%q\n%q\n%s\n%s", source.Include, source.Exclude, source.Rewrite, source.Shift_, source.Cutoff_)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(rules)))
+}
+
+// ValidateRules checks that the source's filters and rewrites are valid.
+func (source *CalendarSource) ValidateRules() error {
+	if source.Writable && source.HasRules() {
+		return errors.New("a writable source cannot have rules, since the changed events would be written back")
+	}
+	for _, filter := range slices.Concat(source.Include, source.Exclude) {
+		if !slices.Contains(SourceFilterFields, filter.Field) {
+			return fmt.Errorf("unknown filter field '%s' (must be one of %s)", filter.Field, strings.Join(SourceFilterFields, ", "))
+		}
+		if _, err := regexp.Compile(filter.Pattern); err != nil {
+			return err
+		}
+	}
+	for _, rewrite := range source.Rewrite {
+		if _, err := regexp.Compile(rewrite.Pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceRules are the compiled rules of a source.
+type sourceRules struct {
+	source   *CalendarSource
+	includes []*regexp.Regexp
+	excludes []*regexp.Regexp
+	rewrites []*regexp.Regexp
+	cutoff   time.Time
+}
+
+func (source *CalendarSource) compileRules(now time.Time) (*sourceRules, error) {
+	rules := &sourceRules{source: source}
+	compile := func(patterns []string) ([]*regexp.Regexp, error) {
+		compiled := []*regexp.Regexp{}
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			compiled = append(compiled, re)
+		}
+		return compiled, nil
+	}
+	patterns := func(filters []SourceFilter) []string {
+		ps := []string{}
+		for _, filter := range filters {
+			ps = append(ps, filter.Pattern)
+		}
+		return ps
+	}
+
+	var err error
+	if rules.includes, err = compile(patterns(source.Include)); err != nil {
+		return nil, err
+	}
+	if rules.excludes, err = compile(patterns(source.Exclude)); err != nil {
+		return nil, err
+	}
+	rewritePatterns := []string{}
+	for _, rewrite := range source.Rewrite {
+		rewritePatterns = append(rewritePatterns, rewrite.Pattern)
+	}
+	if rules.rewrites, err = compile(rewritePatterns); err != nil {
+		return nil, err
+	}
+	if source.Cutoff_ != 0 {
+		rules.cutoff = now.Add(-source.Cutoff_)
+	}
+	return rules, nil
+}
+
+// apply filters and transforms the event, in this order:
+// an event that matches none of the includes (if any), that matches an exclude, or that ended before the cutoff is dropped,
+// and otherwise its summary is rewritten, and its time shifted.
+//
+// If the event is dropped, the rule that dropped it is returned.
+func (rules *sourceRules) apply(props *EventProperties) (string, error) {
+	source := rules.source
+
+	included := len(source.Include) == 0
+	for i, filter := range source.Include {
+		if filter.match(rules.includes[i], props) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return "include", nil
+	}
+	for i, filter := range source.Exclude {
+		if filter.match(rules.excludes[i], props) {
+			return "exclude " + filter.String(), nil
+		}
+	}
+	if !rules.cutoff.IsZero() && endsBefore(props, rules.cutoff) {
+		return "cutoff", nil
+	}
+
+	for i, rewrite := range source.Rewrite {
+		props.Summary = rules.rewrites[i].ReplaceAllString(props.Summary, rewrite.Replacement)
+	}
+	if source.Shift_ != 0 {
+		if err := props.shift(source.Shift_); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// applyRules applies the rules of the source to the events.
+// The returned map counts the dropped events by the rule that dropped them.
+func (source *CalendarSource) applyRules(eventsProps []EventProperties, now time.Time) ([]EventProperties, map[string]int, error) {
+	if !source.HasRules() {
+		return eventsProps, nil, nil
+	}
+	rules, err := source.compileRules(now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dropped := map[string]int{}
+	kept := []EventProperties{}
+	for _, props := range eventsProps {
+		rule, err := rules.apply(&props)
+		if err != nil {
+			return nil, nil, err
+		}
+		if rule != "" {
+			dropped[rule]++
+			continue
+		}
+		kept = append(kept, props)
+	}
+	return kept, dropped, nil
+}
+
+// endsBefore returns true if the event (with all its occurrences) ends before t.
+func endsBefore(props *EventProperties, t time.Time) bool {
+	if !props.End.Before(t) {
+		return false
+	}
+	if !props.Recurrence.IsThereRecurrence() || props.IsOverride() {
+		return true
+	}
+	rruleSet, err := props.GetRruleSet()
+	if err != nil {
+		// Keep what cannot be understood.
+		return false
+	}
+	return rruleSet.After(t.Add(-props.End.Sub(props.Start)), false).IsZero()
+}
+
+// shift moves the event, including its recurrence, by d.
+func (props *EventProperties) shift(d time.Duration) error {
+	props.Start = props.Start.Add(d)
+	props.End = props.End.Add(d)
+	if props.IsOverride() {
+		props.RecurrenceId = props.RecurrenceId.Add(d)
+	}
+
+	if s := props.Recurrence.RRule; s != "" {
+		opt, err := rrule.StrToROption(s)
+		if err != nil {
+			return fmt.Errorf("RRULE parse failed: %s", err)
+		}
+		if !opt.Until.IsZero() {
+			opt.Until = opt.Until.Add(d).In(time.UTC)
+			props.Recurrence.RRule = opt.RRuleString()
+		}
+	}
+
+	for _, dates := range []*string{&props.Recurrence.RDate, &props.Recurrence.ExDate} {
+		if *dates == "" {
+			continue
+		}
+		ts, err := rrule.StrToDatesInLoc(*dates, props.GetTimeZoneLocation())
+		if err != nil {
+			return err
+		}
+		shifted := []string{}
+		for _, t := range ts {
+			shifted = append(shifted, t.Add(d).In(time.UTC).Format("20060102T150405Z"))
+		}
+		*dates = strings.Join(shifted, ",")
+	}
+
+	return nil
+}
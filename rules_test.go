@@ -0,0 +1,182 @@
+package ian
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSourceRules(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	event := func(summary, location string, start time.Time, categories ...string) EventProperties {
+		return EventProperties{
+			Uid:        GenerateUid(),
+			Summary:    summary,
+			Location:   location,
+			Start:      start,
+			End:        start.Add(time.Hour),
+			Categories: categories,
+		}
+	}
+
+	recurring := event("CS101 lecture", "Hall A", now.AddDate(0, -1, 0))
+	recurring.Recurrence.RRule = "FREQ=WEEKLY"
+	ended := event("CS101 lecture", "Hall A", now.AddDate(0, -1, 0))
+	ended.Recurrence.RRule = "FREQ=WEEKLY;COUNT=2"
+
+	source := CalendarSource{
+		Include: []SourceFilter{{Field: "summary", Pattern: "^CS101"}, {Field: "categories", Pattern: "^exam$"}},
+		Exclude: []SourceFilter{{Field: "location", Pattern: "(?i)online"}},
+		Rewrite: []SourceRewrite{{Pattern: `^CS101 (\w+)`, Replacement: "Algorithms $1"}},
+		Shift_:  -30 * time.Minute,
+		Cutoff_: 7 * 24 * time.Hour,
+	}
+	source.Shift = source.Shift_.String()
+	source.Cutoff = source.Cutoff_.String()
+
+	kept, dropped, err := source.applyRules([]EventProperties{
+		event("CS101 lecture", "Hall A", now.AddDate(0, 0, 1)),
+		event("CS101 lecture", "Online", now.AddDate(0, 0, 2)),
+		event("final", "Hall B", now.AddDate(0, 0, 3), "exam"),
+		event("MA201 lecture", "Hall C", now.AddDate(0, 0, 1)),
+		event("CS101 lecture", "Hall A", now.AddDate(0, 0, -8)),
+		recurring,
+		ended,
+	}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summaries := []string{}
+	for _, props := range kept {
+		summaries = append(summaries, props.Summary)
+	}
+	if !slices.Equal(summaries, []string{"Algorithms lecture", "final", "Algorithms lecture"}) {
+		t.Errorf("unexpected kept events: %v", summaries)
+	}
+	if !kept[0].Start.Equal(now.AddDate(0, 0, 1).Add(-30 * time.Minute)) {
+		t.Errorf("expected the event to be shifted, got %s", kept[0].Start)
+	}
+	if dropped["include"] != 1 || dropped["exclude location '(?i)online'"] != 1 || dropped["cutoff"] != 2 {
+		t.Errorf("unexpected dropped counts: %v", dropped)
+	}
+
+	source.Writable = true
+	if err := source.ValidateRules(); err == nil {
+		t.Error("expected rules of a writable source to be invalid")
+	}
+	source.Writable = false
+	source.Exclude = append(source.Exclude, SourceFilter{Field: "description", Pattern: "x"})
+	if err := source.ValidateRules(); err == nil {
+		t.Error("expected an unknown field to be invalid")
+	}
+}
+
+func TestUpdateSourceWithRules(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\n" +
+		"BEGIN:VEVENT\r\nUID:1\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T090000Z\r\nDTEND:20240506T100000Z\r\nSUMMARY:lecture\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:2\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T120000Z\r\nDTEND:20240506T130000Z\r\nSUMMARY:lunch\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	instance := &Instance{Root: t.TempDir()}
+	instance.Config.Sources = map[string]CalendarSource{
+		"school": {Source: server.URL, Type: "ical", Exclude: []SourceFilter{{Field: "summary", Pattern: "lunch"}}},
+	}
+
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := instance.ReadCachedEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Props.Summary != "lecture" {
+		t.Errorf("expected only the lecture to be cached, got %v", events)
+	}
+
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := journal.Sources["school"].Dropped["exclude summary 'lunch'"]; n != 1 {
+		t.Errorf("expected 1 dropped event in the journal, got %d", n)
+	}
+}
+
+func TestChangeRulesOfUnmodifiedSource(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\n" +
+		"BEGIN:VEVENT\r\nUID:1\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T090000Z\r\nDTEND:20240506T100000Z\r\nSUMMARY:lecture\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:2\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T120000Z\r\nDTEND:20240506T130000Z\r\nSUMMARY:lunch\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	var notModified int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"1"`)
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	instance := &Instance{Root: t.TempDir()}
+	source := CalendarSource{Source: server.URL, Type: "ical"}
+	instance.Config.Sources = map[string]CalendarSource{"school": source}
+
+	summaries := func() []string {
+		events, err := instance.ReadCachedEvents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := []string{}
+		for _, event := range events {
+			s = append(s, event.Props.Summary)
+		}
+		slices.Sort(s)
+		return s
+	}
+
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s := summaries(); !slices.Equal(s, []string{"lecture", "lunch"}) {
+		t.Errorf("expected both events to be cached, got %v", s)
+	}
+
+	// Within the lifetime, but the rules have changed.
+	source.Exclude = []SourceFilter{{Field: "summary", Pattern: "lunch"}}
+	instance.Config.Sources["school"] = source
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if notModified != 0 {
+		t.Errorf("expected the source to be fetched again, got %d conditional requests answered with 304", notModified)
+	}
+	if s := summaries(); !slices.Equal(s, []string{"lecture"}) {
+		t.Errorf("expected the new rule to be applied, got %v", s)
+	}
+
+	// With the same rules, the source is not fetched again.
+	source.Lifetime_ = time.Nanosecond
+	instance.Config.Sources["school"] = source
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if notModified != 1 {
+		t.Errorf("expected a conditional request, got %d", notModified)
+	}
+	if s := summaries(); !slices.Equal(s, []string{"lecture"}) {
+		t.Errorf("expected the rule to be kept, got %v", s)
+	}
+}
@@ -47,6 +47,19 @@ func server(addr string, debug bool, instance *ian.Instance) {
 		})
 	})
 
+	// The export endpoint serves the calendars to native sources.
+	r.GET(ian.NativeExportPath, func(c *gin.Context) {
+		calendars, err := instance.ExportCalendars()
+		if err != nil {
+			logger.Printf("export failed: %s\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, calendars)
+	})
+
 	logger.Printf("starting native ian server on %s\n", addr)
 	r.Run(addr)
 }
@@ -73,7 +86,8 @@ func (backend CalDavBackend) GetCalendar(ctx context.Context, path string) (*cal
 func (backend CalDavBackend) GetCalendarObject(ctx context.Context, path string, req *caldav.CalendarCompRequest) (*caldav.CalendarObject, error) {
 	cal := ian.SanitizePath(path)
 
-	events, _, err := backend.instance.ReadEvents(ian.TimeRange{})
+	// The recurrences are part of their recurring events in iCalendar, so they are not generated.
+	events, err := backend.instance.ReadEventFiles()
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +96,15 @@ func (backend CalDavBackend) GetCalendarObject(ctx context.Context, path string,
 		return e.Path.Calendar() == cal
 	})
 
+	todos, err := backend.instance.ReadTodos()
+	if err != nil {
+		return nil, err
+	}
+
+	todos = ian.FilterTodos(&todos, func(t *ian.Todo) bool {
+		return t.Path.Calendar() == cal
+	})
+
 	var lastModified time.Time
 
 	for _, event := range events {
@@ -89,6 +112,11 @@ func (backend CalDavBackend) GetCalendarObject(ctx context.Context, path string,
 			lastModified = mod
 		}
 	}
+	for _, todo := range todos {
+		if mod := todo.Props.Modified; mod.After(lastModified) {
+			lastModified = mod
+		}
+	}
 
 	var calName string
 	if cal != "" {
@@ -97,7 +125,9 @@ func (backend CalDavBackend) GetCalendarObject(ctx context.Context, path string,
 		calName = "main"
 	}
 
-	ics := ian.ToIcal(events, calName)
+	ics := ian.ToIcal(events, todos, calName)
+	backend.instance.Config.SetIcalCalendarColor(ics, cal)
+	backend.instance.SetIcalCalendarExtra(ics, cal)
 	b, err := ian.SerializeIcal(ics)
 	if err != nil {
 		return nil, err
@@ -137,10 +167,20 @@ func (backend CalDavBackend) PutCalendarObject(ctx context.Context, path string,
 		return "", fmt.Errorf("missing ian property '%s' for revision\n", ian.IcalPropGrabTimestamp)
 	}
 
+	// The events and to-dos are matched by their UIDs, so the calendar is rejected before anything is changed.
+	for _, child := range calendar.Children {
+		if (child.Name == ical.CompEvent || child.Name == ical.CompToDo) && child.Props.Get(ical.PropUID) == nil {
+			return "", fmt.Errorf("%s without a UID", child.Name)
+		}
+	}
+
 	cal := ian.SanitizePath(path)
 
 	// events are the current events.
-	events, _, err := backend.instance.ReadEvents(ian.TimeRange{})
+	events, err := backend.instance.ReadEventFiles()
+	if err != nil {
+		return "", err
+	}
 	events = ian.FilterEvents(&events, func(e *ian.Event) bool {
 		return e.Path.Calendar() == cal
 	})
@@ -151,7 +191,7 @@ func (backend CalDavBackend) PutCalendarObject(ctx context.Context, path string,
 
 	for _, event := range events {
 		i := slices.IndexFunc(proposedEvents, func(evProps ical.Event) bool {
-			return evProps.Props.Get(ical.PropUID).Value == event.Props.Uid
+			return isSameEvent(event.Props, evProps)
 		})
 
 		if i == -1 {
@@ -174,6 +214,7 @@ func (backend CalDavBackend) PutCalendarObject(ctx context.Context, path string,
 				return "", err
 			}
 			hasPut = true
+			continue
 		}
 
 		proposedEvent := proposedEvents[i]
@@ -210,7 +251,7 @@ func (backend CalDavBackend) PutCalendarObject(ctx context.Context, path string,
 	if !hasPut {
 		for _, proposedEvent := range proposedEvents {
 			i := slices.IndexFunc(events, func(evProps ian.Event) bool {
-				return evProps.Props.Uid == proposedEvent.Props.Get(ical.PropUID).Value
+				return isSameEvent(evProps.Props, proposedEvent)
 			})
 
 			if i == -1 {
@@ -236,12 +277,138 @@ func (backend CalDavBackend) PutCalendarObject(ctx context.Context, path string,
 		}
 	}
 
-	if !hasPut {
+	hasPutTodo, err := backend.putTodos(cal, calendar, grabbedAt)
+	if err != nil {
+		return "", err
+	}
+
+	if !hasPut && !hasPutTodo {
 		return "", errors.New("no new/modified/deleted event")
 	}
 
 	return "", nil
 }
+
+// isSameEvent returns true if props and icalEvent have the same UID and recurrence ID, i.e. they are the same event or override.
+func isSameEvent(props ian.EventProperties, icalEvent ical.Event) bool {
+	if icalEvent.Props.Get(ical.PropUID).Value != props.Uid {
+		return false
+	}
+	if icalEvent.Props.Get(ical.PropRecurrenceID) == nil {
+		return !props.IsOverride()
+	}
+	recurrenceId, err := icalEvent.Props.DateTime(ical.PropRecurrenceID, ian.GetTimeZone())
+	return err == nil && recurrenceId.Equal(props.RecurrenceId)
+}
+
+// putTodos creates, updates and deletes the to-dos in cal to match the to-dos in calendar.
+func (backend CalDavBackend) putTodos(cal string, calendar *ical.Calendar, grabbedAt time.Time) (hasPut bool, err error) {
+	todos, err := backend.instance.ReadTodos()
+	if err != nil {
+		return false, err
+	}
+	todos = ian.FilterTodos(&todos, func(t *ian.Todo) bool {
+		return t.Path.Calendar() == cal
+	})
+
+	proposedTodos := []*ical.Component{}
+	for _, child := range calendar.Children {
+		if child.Name == ical.CompToDo {
+			proposedTodos = append(proposedTodos, child)
+		}
+	}
+
+	for _, todo := range todos {
+		i := slices.IndexFunc(proposedTodos, func(comp *ical.Component) bool {
+			return comp.Props.Get(ical.PropUID).Value == todo.Props.Uid
+		})
+
+		if i == -1 {
+			// No to-do match: delete.
+			if todo.Props.Modified.After(grabbedAt) {
+				return false, errors.New("client wants to delete outdated to-do. synchronize changes first.")
+			}
+
+			file := todo.Path.Filepath(backend.instance)
+
+			err := backend.instance.Sync(func() error {
+				return os.Remove(file)
+			}, ian.SyncEvent{
+				Type:    ian.SyncEventDelete,
+				Files:   []string{file},
+				Message: fmt.Sprintf("ian: [CalDAV request] delete to-do: '%s'", todo.Path),
+			}, false, nil)
+
+			if err != nil {
+				return false, err
+			}
+			hasPut = true
+			continue
+		}
+
+		props, err := ian.FromIcalTodo(proposedTodos[i])
+		if err != nil {
+			return false, err
+		}
+		if !props.Modified.Equal(todo.Props.Modified) {
+			// To-do match but properties changed: update.
+			if todo.Props.Modified.After(grabbedAt) {
+				return false, errors.New("client wants to update outdated to-do. synchronize changes first.")
+			}
+
+			todo.Props = props
+
+			err := backend.instance.Sync(func() error {
+				return todo.Write(backend.instance)
+			}, ian.SyncEvent{
+				Type:    ian.SyncEventUpdate,
+				Files:   []string{todo.Path.Filepath(backend.instance)},
+				Message: fmt.Sprintf("ian: [CalDAV request] edit to-do '%s'", todo.Path),
+			}, false, nil)
+
+			if err != nil {
+				return false, err
+			}
+			hasPut = true
+		}
+	}
+
+	for _, proposedTodo := range proposedTodos {
+		i := slices.IndexFunc(todos, func(todo ian.Todo) bool {
+			return todo.Props.Uid == proposedTodo.Props.Get(ical.PropUID).Value
+		})
+
+		if i == -1 {
+			// To-do does not exist; create it.
+			props, err := ian.FromIcalTodo(proposedTodo)
+			if err != nil {
+				return false, err
+			}
+			if props.Created.Before(grabbedAt) {
+				return false, errors.New("to-do created before grab date. the to-do may have existed and was deleted, but not yet deleted for the client.")
+			}
+			todo, err := backend.instance.NewTodo(props, cal)
+			if err != nil {
+				return false, err
+			}
+
+			err = backend.instance.Sync(func() error {
+				return todo.Write(backend.instance)
+			}, ian.SyncEvent{
+				Type:    ian.SyncEventCreate,
+				Files:   []string{todo.Path.Filepath(backend.instance)},
+				Message: fmt.Sprintf("ian: [CalDAV request] create to-do '%s'", todo.Path),
+			}, false, nil)
+
+			if err != nil {
+				return false, err
+			}
+			hasPut = true
+		}
+	}
+
+	return hasPut, nil
+}
 func (backend CalDavBackend) QueryCalendarObjects(ctx context.Context, query *caldav.CalendarQuery) ([]caldav.CalendarObject, error) {
 	backend.logger.Printf("tried to query objects with %v\n", *query)
 	return nil, nil
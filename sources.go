@@ -2,22 +2,32 @@ package ian
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"image/color"
+	"io/fs"
 	"log"
 	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/emersion/go-ical"
 )
 
 const CacheCalendar string = ".sources"
 const CacheJournalFileName string = ".cache-journal.toml"
 const DefaultCacheLifetime time.Duration = 2 * time.Hour
+const DefaultSourceTimeout time.Duration = 30 * time.Second
+
+// MaxConcurrentSourceUpdates is how many sources are updated at the same time.
+const MaxConcurrentSourceUpdates int = 4
 
 type CalendarSource struct {
 	Source string
@@ -25,11 +35,44 @@ type CalendarSource struct {
 	// "native" for a native, dynamic ian calendar.
 	// "caldav" for a dynamic CalDAV.
 	// "ical" for a static HTTP iCalendar.
+	// "file" for a local iCalendar file, which is updated when it is modified.
+	// "vdir" for a local directory with an iCalendar file for each event (like vdirsyncer's), which is updated when it is modified.
+	// "command" for the iCalendar output of a shell command.
 	Type string
+	// Writable is true if changes to the source's events should be written back to it.
+	// Only "caldav" sources can be writable.
+	Writable bool
 	// Parsed with time.ParseDuration...
 	Lifetime string
 	// and inserted here:
+	// If unset, the refresh interval suggested by the source is used, or otherwise DefaultCacheLifetime.
 	Lifetime_ time.Duration
+	// Timeout is how long an update may take (parsed with time.ParseDuration), and defaults to DefaultSourceTimeout.
+	Timeout  string
+	Timeout_ time.Duration
+	// Auth is how requests to the source are authenticated (see SourceAuthTypes), if at all:
+	// "basic" for basic auth with Username and the password.
+	// "bearer" for a bearer token.
+	// "header" for the secret as the value of Header (e.g. 'X-API-Key').
+	Auth     string
+	Username string
+	Header   string
+	// PasswordCommand is a shell command that prints the password (or token) of Auth, e.g. 'pass show cal/work'.
+	PasswordCommand string
+	// PasswordEnv is the environment variable with the password (or token) of Auth, instead of PasswordCommand.
+	PasswordEnv string
+	// Include are the filters of the events to keep. If empty, all events are kept.
+	Include []SourceFilter
+	// Exclude are the filters of the events to drop.
+	Exclude []SourceFilter
+	// Rewrite are applied in order to the summaries of the events.
+	Rewrite []SourceRewrite
+	// Shift moves all events (parsed with time.ParseDuration, and can be negative).
+	Shift  string
+	Shift_ time.Duration
+	// Cutoff drops events that ended longer ago than this (parsed with time.ParseDuration).
+	Cutoff  string
+	Cutoff_ time.Duration
 }
 
 type CacheJournal struct {
@@ -37,112 +80,295 @@ type CacheJournal struct {
 }
 
 type CacheJournalSource struct {
+	// LastUpdate is when the source was last updated successfully.
 	LastUpdate time.Time
+	// Error is the error of the last update, if it failed. The cache then keeps the events of the last successful update.
+	Error     string
+	ErrorTime time.Time
+	// ETag and LastModified are the validators of the last download, used to only download a changed calendar.
+	ETag         string
+	LastModified string
+	// ModTime is the modification time of a "file" or "vdir" source at the last update.
+	ModTime time.Time
+	// Calendar describes the source's calendar, as suggested by the source itself.
+	Calendar IcalCalendarInfo
+	// Dropped counts the events dropped by each rule of the source (see CalendarSource.applyRules) in the last update.
+	Dropped map[string]int
+	// Rules is the hash of the source's rules at the last update. If the rules have changed since, the source is fetched
+	// again, since the cache was written with the old rules.
+	Rules string
 }
 
-func (i *CalendarSource) Import(name string) ([]EventProperties, error) {
+// Import fetches the source's calendar.
+func (i *CalendarSource) Import(name string) (*ical.Calendar, error) {
 	if Verbose {
 		log.Printf("source '%s' is being imported as '%s'\n", name, i.Type)
 	}
 	switch i.Type {
-	case "caldav": // TODO
-		return nil, errors.New("caldav calendars not yet supported")
+	case "caldav":
+		ics, _, err := i.importCalDav(context.Background(), name)
+		return ics, err
+	case "native":
+		calendars, err := i.importNative(context.Background(), name)
+		if err != nil {
+			return nil, err
+		}
+		events := []Event{}
+		todos := []Todo{}
+		for _, calendar := range calendars {
+			for _, props := range calendar.Events {
+				events = append(events, Event{Props: props})
+			}
+			for _, props := range calendar.Todos {
+				todos = append(todos, Todo{Props: props})
+			}
+		}
+		return ToIcal(events, todos, ""), nil
 	case "ical":
+		return i.importIcal(context.Background(), name, &CacheJournalSource{})
+	case "file":
+		return i.importFile(name)
+	case "vdir":
+		return i.importVdir(name)
+	case "command":
+		return i.importCommand(context.Background(), name)
+	default:
+		return nil, errors.New("invalid calendar type '" + i.Type + "'")
+	}
+}
+
+// importIcal downloads the source's iCalendar.
+// If journalSource has validators from an earlier download, the calendar is only downloaded if it has changed since.
+// Otherwise, the returned calendar is nil.
+// The validators of the download are stored in journalSource.
+func (i *CalendarSource) importIcal(ctx context.Context, name string, journalSource *CacheJournalSource) (*ical.Calendar, error) {
+	if Verbose {
+		log.Printf("downloading iCalendar '%s'\n", i.Source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, i.Source, nil)
+	if err != nil {
+		return nil, err
+	}
+	if journalSource.ETag != "" {
+		req.Header.Set("If-None-Match", journalSource.ETag)
+	}
+	if journalSource.LastModified != "" {
+		req.Header.Set("If-Modified-Since", journalSource.LastModified)
+	}
+
+	client, err := i.httpClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("source '%s': %s", name, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
 		if Verbose {
-			log.Printf("downloading iCalendar '%s'\n", i.Source)
+			log.Printf("source '%s' has not been modified\n", name)
 		}
-		resp, err := http.Get(i.Source)
+		return nil, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("source '%s' HTTP request failed: %s", name, resp.Status)
+	}
+
+	ics, err := ParseIcal(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	journalSource.ETag = resp.Header.Get("ETag")
+	journalSource.LastModified = resp.Header.Get("Last-Modified")
+
+	return ics, nil
+}
+
+// ImportAndUse fetches the source's calendar and caches it, within the source's timeout.
+// Unlike UpdateSourcesByName, the journal is neither used nor written.
+func (i *CalendarSource) ImportAndUse(ctx context.Context, instance *Instance, name string) error {
+	return i.update(ctx, instance, name, &CacheJournalSource{})
+}
+
+// importAndUse is like ImportAndUse, but an unchanged iCalendar source is not downloaded and cached again (see importIcal).
+func (i *CalendarSource) importAndUse(ctx context.Context, instance *Instance, name string, journalSource *CacheJournalSource) error {
+	if rules := i.rulesHash(); rules != journalSource.Rules {
+		// The cache was written with other rules, so the source is fetched again even if it has not been modified.
+		journalSource.ETag = ""
+		journalSource.LastModified = ""
+		journalSource.ModTime = time.Time{}
+		journalSource.Rules = rules
+	}
+
+	if i.Type == "native" {
+		calendars, err := i.importNative(ctx, name)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if resp.StatusCode != 200 {
-			return nil, fmt.Errorf("source '%s' HTTP request failed: %s", name, resp.Status)
+		if journalSource.Dropped, err = i.applyNativeRules(calendars, time.Now()); err != nil {
+			return err
 		}
-		defer resp.Body.Close()
-		ics, err := ParseIcal(resp.Body)
-		if err != nil {
-			return nil, err
+		return instance.cacheNativeCalendars(name, calendars)
+	}
+
+	var ics *ical.Calendar
+	var journal *CalDavJournal
+	var err error
+	switch i.Type {
+	case "caldav":
+		ics, journal, err = i.importCalDav(ctx, name)
+	case "ical":
+		if _, err := os.Stat(filepath.Join(instance.getCacheDir(), name)); err != nil {
+			// Without a cache, the calendar has to be downloaded regardless.
+			journalSource.ETag = ""
+			journalSource.LastModified = ""
 		}
-		cal, err := FromIcal(ics)
-		if err != nil {
-			return nil, err
+		ics, err = i.importIcal(ctx, name, journalSource)
+		if err == nil && ics == nil {
+			// Not modified, so the cache is up to date.
+			return nil
+		}
+	case "file", "vdir":
+		modTime, statErr := i.localModTime()
+		if statErr != nil {
+			return fmt.Errorf("source '%s': %s", name, statErr)
+		}
+		if _, statErr := os.Stat(filepath.Join(instance.getCacheDir(), name)); statErr == nil && modTime.Equal(journalSource.ModTime) {
+			// Not modified, so the cache is up to date.
+			return nil
+		}
+		if i.Type == "file" {
+			ics, err = i.importFile(name)
+		} else {
+			ics, err = i.importVdir(name)
 		}
-		return cal, nil
+		journalSource.ModTime = modTime
+	case "command":
+		ics, err = i.importCommand(ctx, name)
 	default:
-		return nil, errors.New("invalid calendar type '" + i.Type + "'")
+		ics, err = i.Import(name)
+	}
+	if err != nil {
+		return err
 	}
-}
 
-func (i *CalendarSource) ImportAndUse(instance *Instance, name string) error {
-	cal, err := i.Import(name)
+	eventsProps, err := FromIcal(ics)
+	if err != nil {
+		return err
+	}
+	todosProps, err := FromIcalTodos(ics)
 	if err != nil {
 		return err
 	}
 
-	instance.CacheEvents(name, cal)
+	journalSource.Calendar = ReadIcalCalendarInfo(ics)
 
-	return nil
+	if eventsProps, journalSource.Dropped, err = i.applyRules(eventsProps, time.Now()); err != nil {
+		return err
+	}
+
+	return instance.replaceCache(name, func(subDir string) error {
+		if err := instance.cacheEventsIn(subDir, eventsProps, todosProps); err != nil {
+			return err
+		}
+		if journal != nil {
+			return writeCalDavJournal(filepath.Join(instance.getCacheDir(), subDir, CalDavJournalFileName), *journal)
+		}
+		return nil
+	})
 }
 
 func (instance *Instance) DeleteCache() error {
 	return os.RemoveAll(instance.getCacheDir())
 }
 
-func (instance *Instance) CleanSources() error {
+func (instance *Instance) CleanSources(ctx context.Context) error {
 	if err := instance.DeleteCache(); err != nil {
 		return err
 	}
 
-	if err := instance.UpdateSources(); err != nil {
+	if err := instance.UpdateSources(ctx); err != nil {
 		return err
 	}
 	return nil
 }
 
-// UpdateSources updates the configured sources according to their lifetimes.
-func (instance *Instance) UpdateSources() error {
-	path := filepath.Join(instance.getCacheDir(), CacheJournalFileName)
-	CreateFileIfMissing(path)
-	buf, err := os.ReadFile(path)
-	if err != nil {
-		return err
-	}
+func (instance *Instance) getCacheJournalPath() string {
+	return filepath.Join(instance.getCacheDir(), CacheJournalFileName)
+}
 
+// ReadCacheJournal reads the cache journal, which keeps track of the sources' updates.
+func (instance *Instance) ReadCacheJournal() (CacheJournal, error) {
 	var journal CacheJournal
-	if _, err := toml.Decode(string(buf), &journal); err != nil {
-		return err
+	if _, err := toml.DecodeFile(instance.getCacheJournalPath(), &journal); err != nil && !os.IsNotExist(err) {
+		return CacheJournal{}, err
 	}
-	var isJournalChanged bool
 	if journal.Sources == nil {
 		journal.Sources = map[string]CacheJournalSource{}
-		isJournalChanged = true
+	}
+	return journal, nil
+}
+
+func (instance *Instance) writeCacheJournal(journal CacheJournal, now time.Time) error {
+	if Verbose {
+		log.Println("updating journal")
+	}
+
+	bufOut := new(bytes.Buffer)
+	bufOut.WriteString(
+		fmt.Sprintf(
+			"# This file is automatically generated and managed.\n# Last change: %s\n\n",
+			now.Format(DefaultTimeLayout),
+		),
+	)
+	if err := toml.NewEncoder(bufOut).Encode(journal); err != nil {
+		return err
+	}
+
+	if err := CreateDir(instance.getCacheDir()); err != nil {
+		return err
+	}
+	return os.WriteFile(instance.getCacheJournalPath(), bufOut.Bytes(), 0644)
+}
+
+// UpdateSources updates the configured sources according to their lifetimes.
+// The sources are updated concurrently (see MaxConcurrentSourceUpdates), and each update is canceled after the source's timeout.
+// A source that fails to update keeps its cache, and the error is recorded in the journal and reported as a warning.
+// Such a source is updated again the next time.
+// If ctx is canceled, the remaining updates are canceled and the cancellation is returned.
+func (instance *Instance) UpdateSources(ctx context.Context) error {
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		return err
 	}
 
 	now := time.Now()
 	unsatisfiedSources := maps.Clone(instance.Config.Sources)
 
+	updates := []sourceUpdate{}
+
 	for name, journalSource := range journal.Sources {
 		source, ok := unsatisfiedSources[name]
 		if !ok {
-			log.Printf("warning: in cache journal '%s': source with the name '%s' does not exist. use 'ian sources --clean' to resolve.\n", path, name)
+			log.Printf("warning: in cache journal '%s': source with the name '%s' does not exist. use 'ian sources --clean' to resolve.\n", instance.getCacheJournalPath(), name)
 			continue
 		}
 
 		var lifetime time.Duration
 		if source.Lifetime_ != 0 {
 			lifetime = source.Lifetime_
+		} else if journalSource.Calendar.RefreshInterval != 0 {
+			lifetime = journalSource.Calendar.RefreshInterval
 		} else {
 			lifetime = DefaultCacheLifetime
 		}
 
-		if journalSource.LastUpdate.Add(lifetime).Before(now) {
-			// Lifetime expired, update the source.
-			journalSource.LastUpdate = now
-			journal.Sources[name] = journalSource
-			isJournalChanged = true
-			if err := source.ImportAndUse(instance, name); err != nil {
-				return err
-			}
+		if journalSource.LastUpdate.Add(lifetime).Before(now) || source.isModified(journalSource) || source.rulesHash() != journalSource.Rules {
+			// Lifetime expired (or the local source or the rules were modified), update the source.
+			updates = append(updates, sourceUpdate{name, source, journalSource})
 		}
 
 		delete(unsatisfiedSources, name)
@@ -159,40 +385,262 @@ func (instance *Instance) UpdateSources() error {
 			log.Printf("source '%s' is not provided in journal. it will be updated and added.\n", name)
 		}
 
-		if err := source.ImportAndUse(instance, name); err != nil {
-			return err
+		updates = append(updates, sourceUpdate{name, source, CacheJournalSource{}})
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return instance.runSourceUpdates(ctx, journal, updates, now)
+}
+
+// UpdateSourcesByName updates the named sources now, regardless of their lifetimes.
+// The updates are run and recorded in the journal like by UpdateSources.
+func (instance *Instance) UpdateSourcesByName(ctx context.Context, names []string) error {
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		return err
+	}
+
+	updates := []sourceUpdate{}
+	for _, name := range names {
+		source, ok := instance.Config.Sources[name]
+		if !ok {
+			return fmt.Errorf("no such source: '%s'", name)
 		}
+		updates = append(updates, sourceUpdate{name, source, journal.Sources[name]})
+	}
 
-		journal.Sources[name] = CacheJournalSource{
-			LastUpdate: now,
+	return instance.runSourceUpdates(ctx, journal, updates, time.Now())
+}
+
+// sourceUpdate is a source to update, and its entry in the journal.
+type sourceUpdate struct {
+	name          string
+	source        CalendarSource
+	journalSource CacheJournalSource
+}
+
+// runSourceUpdates runs the updates concurrently, records their results in the journal and writes it.
+func (instance *Instance) runSourceUpdates(ctx context.Context, journal CacheJournal, updates []sourceUpdate, now time.Time) error {
+	recordError := func(u sourceUpdate, err error) {
+		log.Printf("warning: source '%s' could not be updated, and its cached events may be outdated: %s\n", u.name, err)
+		u.journalSource.Error = err.Error()
+		u.journalSource.ErrorTime = now
+		journal.Sources[u.name] = u.journalSource
+	}
+
+	// The passwords are resolved one at a time before the updates, and outside of their timeouts, since a password
+	// command may prompt for a passphrase. They are then cached for the updates (see CalendarSource.password).
+	authorized := []sourceUpdate{}
+	for _, u := range updates {
+		if u.source.Auth != "" {
+			if _, err := u.source.password(ctx); err != nil {
+				if ctx.Err() == nil {
+					recordError(u, err)
+				}
+				continue
+			}
 		}
-		isJournalChanged = true
+		authorized = append(authorized, u)
+	}
+	updates = authorized
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var finished int
+	queue := make(chan sourceUpdate)
+
+	for range min(MaxConcurrentSourceUpdates, len(updates)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range queue {
+				started := time.Now()
+				err := u.source.update(ctx, instance, u.name, &u.journalSource)
+
+				mutex.Lock()
+				finished++
+				switch {
+				case ctx.Err() != nil:
+					// Canceled, so the result is not recorded.
+				case err != nil:
+					recordError(u, err)
+				default:
+					if Verbose {
+						log.Printf("source '%s' updated in %s (%d/%d)\n", u.name, time.Since(started).Round(time.Millisecond), finished, len(updates))
+					}
+					u.journalSource.LastUpdate = now
+					u.journalSource.Error = ""
+					u.journalSource.ErrorTime = time.Time{}
+					journal.Sources[u.name] = u.journalSource
+				}
+				mutex.Unlock()
+			}
+		}()
 	}
 
-	// Write updated journal
+	for _, u := range updates {
+		queue <- u
+	}
+	close(queue)
+	wg.Wait()
 
-	if isJournalChanged {
-		if Verbose {
-			log.Println("updating journal")
+	// Write updated journal, including the updates that finished before any cancellation.
+
+	if err := instance.writeCacheJournal(journal, now); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// update imports and caches the source, within the source's timeout.
+func (source *CalendarSource) update(ctx context.Context, instance *Instance, name string, journalSource *CacheJournalSource) error {
+	timeout := DefaultSourceTimeout
+	if source.Timeout_ != 0 {
+		timeout = source.Timeout_
+	}
+
+	if Verbose {
+		log.Printf("updating source '%s' (timeout %s)\n", name, timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The journal is only changed by a successful update, so that a failed one is not taken as up to date.
+	updated := *journalSource
+	err := source.importAndUse(ctx, instance, name, &updated)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		return err
+	}
+	*journalSource = updated
+	return nil
+}
+
+// useSourceCalendarColors colors the calendars of sources like the sources suggest, unless they are configured locally.
+func (instance *Instance) useSourceCalendarColors() {
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		return
+	}
+
+	for name, journalSource := range journal.Sources {
+		calendar := "." + name
+		if _, ok := instance.Config.Sources[name]; !ok || journalSource.Calendar.Color == (color.RGBA{}) {
+			continue
+		}
+		if _, ok := instance.Config.Calendars[calendar]; ok {
+			continue
+		}
+		if instance.Config.Calendars == nil {
+			instance.Config.Calendars = map[string]CalendarConfig{}
 		}
+		instance.Config.Calendars[calendar] = CalendarConfig{Color: journalSource.Calendar.Color}
+	}
+}
+
+// RemoveSource removes the source from the configuration, along with its cache and journal entry.
+func (instance *Instance) RemoveSource(name string) error {
+	if err := removeSourceConfig(instance.Root, name); err != nil {
+		return err
+	}
+	delete(instance.Config.Sources, name)
+
+	if err := os.RemoveAll(filepath.Join(instance.getCacheDir(), name)); err != nil {
+		return err
+	}
+	return instance.editCacheJournal(func(journal *CacheJournal) {
+		delete(journal.Sources, name)
+	})
+}
 
-		bufOut := new(bytes.Buffer)
-		bufOut.WriteString(
-			fmt.Sprintf(
-				"# This file is automatically generated and managed.\n# Last change: %s\n\n",
-				now.Format(DefaultTimeLayout),
-			),
-		)
-		if err := toml.NewEncoder(bufOut).Encode(journal); err != nil {
+// RenameSource renames the source in the configuration (including the configurations of its calendars), and moves its cache and journal entry.
+func (instance *Instance) RenameSource(name, newName string) error {
+	if err := ValidateSourceName(newName); err != nil {
+		return err
+	}
+	if err := renameSourceConfig(instance.Root, name, newName); err != nil {
+		return err
+	}
+	instance.Config.Sources[newName] = instance.Config.Sources[name]
+	delete(instance.Config.Sources, name)
+
+	cacheDir := filepath.Join(instance.getCacheDir(), name)
+	if _, err := os.Stat(cacheDir); err == nil {
+		if err := os.Rename(cacheDir, filepath.Join(instance.getCacheDir(), newName)); err != nil {
 			return err
 		}
+	}
+	return instance.editCacheJournal(func(journal *CacheJournal) {
+		if journalSource, ok := journal.Sources[name]; ok {
+			journal.Sources[newName] = journalSource
+			delete(journal.Sources, name)
+		}
+	})
+}
 
-		if err := os.WriteFile(path, bufOut.Bytes(), 0644); err != nil {
+// SetSource sets the attribute (see SetSourceConfig) of the source.
+// If the attribute changes what the source fetches, its journal entry is removed, so that it is updated anew.
+func (instance *Instance) SetSource(name, key, value string) error {
+	if err := SetSourceConfig(instance.Root, name, key, value); err != nil {
+		return err
+	}
+	if key == "lifetime" || key == "timeout" || key == "writable" {
+		return nil
+	}
+	return instance.editCacheJournal(func(journal *CacheJournal) {
+		delete(journal.Sources, name)
+	})
+}
+
+// editCacheJournal changes and writes the cache journal, if there is one.
+func (instance *Instance) editCacheJournal(edit func(*CacheJournal)) error {
+	if _, err := os.Stat(instance.getCacheJournalPath()); os.IsNotExist(err) {
+		return nil
+	}
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		return err
+	}
+	edit(&journal)
+	return instance.writeCacheJournal(journal, time.Now())
+}
+
+// SourceCacheSize returns the size in bytes of the source's cache.
+func (instance *Instance) SourceCacheSize(name string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(filepath.Join(instance.getCacheDir(), name), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
 			return err
 		}
+		if !d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
 	}
+	return size, err
+}
 
-	return nil
+// SourceOfCalendar returns the name of the source whose cache is the calendar, if it is a source calendar.
+// Source calendars are named after their sources, prefixed by a dot (e.g. '.joe').
+// The calendars of native sources also have the calendar name (e.g. '.team:work', see SourceCalendarSeparator).
+func SourceOfCalendar(calendar string) (string, bool) {
+	name, ok := strings.CutPrefix(calendar, ".")
+	name, _, _ = strings.Cut(name, SourceCalendarSeparator)
+	return name, ok
 }
 
 func (instance *Instance) getCacheDir() string {
@@ -200,76 +648,221 @@ func (instance *Instance) getCacheDir() string {
 }
 
 func (instance *Instance) ReadCachedEvents() ([]Event, error) {
+	events, _, err := instance.readCache()
+	return events, err
+}
+
+func (instance *Instance) ReadCachedTodos() ([]Todo, error) {
+	_, todos, err := instance.readCache()
+	return todos, err
+}
+
+// readCache reads the events and to-dos of every source in the cache.
+func (instance *Instance) readCache() ([]Event, []Todo, error) {
 	events := []Event{}
+	todos := []Todo{}
 	cacheDir := instance.getCacheDir()
 
 	cacheDirInfo, err := os.Stat(cacheDir)
 
 	if os.IsNotExist(err) {
-		return events, nil
+		return events, todos, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("error checking cache dir '%s': %s", cacheDir, err)
+		return nil, nil, fmt.Errorf("error checking cache dir '%s': %s", cacheDir, err)
 	}
 	if !cacheDirInfo.IsDir() {
-		return nil, fmt.Errorf("'%s' is not a directory.\n", cacheDir)
+		return nil, nil, fmt.Errorf("'%s' is not a directory.\n", cacheDir)
 	}
 
 	sourceDirs, err := os.ReadDir(cacheDir)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for _, sourceDir := range sourceDirs {
 		if strings.HasPrefix(sourceDir.Name(), ".") {
 			continue
 		}
-		if _, ok := instance.Config.Sources[sourceDir.Name()]; !ok {
+		source, ok := instance.Config.Sources[sourceDir.Name()]
+		if !ok {
 			log.Printf("warning: ignored unknown source entry '%s' in '%s'. use 'ian sources --clean' to resolve.\n", sourceDir.Name(), cacheDir)
 			continue
 		}
 
-		propsList, err := instance.readDir(filepath.Join(cacheDir, sourceDir.Name()))
-		if err != nil {
-			return nil, err
+		// A native source has a directory for each of its calendars.
+		calendars := []string{"." + sourceDir.Name()}
+		if source.Type == "native" {
+			calendars = []string{}
+			calDirs, err := readCalendarDirs(filepath.Join(cacheDir, sourceDir.Name()))
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, calDir := range calDirs {
+				calendars = append(calendars, "."+sourceDir.Name()+SourceCalendarSeparator+calDir)
+			}
 		}
 
-		for name, props := range propsList {
-			path, err := NewEventPath("."+sourceDir.Name(), name)
+		for _, calendar := range calendars {
+			calEvents, calTodos, err := instance.readCacheCalendar(calendar, !source.Writable)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			events = append(events, Event{
-				Path:     path,
-				Props:    props,
-				Type:     EventTypeCache,
-				Constant: true,
-			})
+			events = append(events, calEvents...)
+			todos = append(todos, calTodos...)
 		}
 	}
 
-	return events, nil
+	return events, todos, nil
 }
 
-func (instance *Instance) CacheEvent(subDir string, props EventProperties) error {
-	path := filepath.Join(instance.getCacheDir(), subDir)
-	name, err := instance.getAvailableFilename(path, props.FormatName())
+// readCacheCalendar reads the events and to-dos of a source calendar in the cache.
+func (instance *Instance) readCacheCalendar(calendar string, constant bool) ([]Event, []Todo, error) {
+	events := []Event{}
+	todos := []Todo{}
+
+	eventsProps, todosProps, err := instance.readDir(instance.getCalendarDir(calendar))
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	for name, props := range eventsProps {
+		path, err := NewEventPath(calendar, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		events = append(events, Event{
+			Path:     path,
+			Props:    props,
+			Type:     EventTypeCache,
+			Constant: constant,
+		})
 	}
-	return props.Write(filepath.Join(path, name))
+
+	for name, props := range todosProps {
+		path, err := NewEventPath(calendar, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		todos = append(todos, Todo{
+			Path:     path,
+			Props:    props,
+			Constant: true,
+		})
+	}
+
+	return events, todos, nil
+}
+
+// CacheEvent caches an event of a source. i is the index of the event in the source, which tells events without a UID
+// apart (see fallbackCacheKey).
+func (instance *Instance) CacheEvent(subDir string, i int, props EventProperties) error {
+	key := props.Uid
+	if key == "" {
+		key = fallbackCacheKey(props.Start, props.Summary, i)
+	}
+	return writeCacheFile(filepath.Join(instance.getCacheDir(), subDir, CacheFilename(key, props.RecurrenceId)), props.Write)
 }
 
-// CacheEvents collectively caches a list of events under a certain directory.
-func (instance *Instance) CacheEvents(name string, eventsProps []EventProperties) error {
-	// First empty the specified cache directory
-	instance.clearDir(filepath.Join(CacheCalendar, name))
+// CacheTodo caches a to-do of a source. i is the index of the to-do in the source, like for CacheEvent.
+func (instance *Instance) CacheTodo(subDir string, i int, props TodoProperties) error {
+	key := props.Uid
+	if key == "" {
+		key = fallbackCacheKey(props.Start, props.Summary, i)
+	}
+	return writeCacheFile(filepath.Join(instance.getCacheDir(), subDir, CacheFilename(key, time.Time{})), props.Write)
+}
 
-	for _, props := range eventsProps {
-		if err := instance.CacheEvent(name, props); err != nil {
+// fallbackCacheKey returns what a component without a UID is cached by instead, which is derived from its start,
+// summary and index in the source. Components without a UID are thus never taken for duplicates of each other.
+func fallbackCacheKey(start time.Time, summary string, i int) string {
+	key := fmt.Sprintf("%s\n%s\n%d", start.In(time.UTC).Format("20060102T150405Z"), summary, i)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+}
+
+// writeCacheFile writes a cached event or to-do, unless a component with the same UID (and recurrence ID) has already been cached.
+func writeCacheFile(path string, write func(string) error) error {
+	if _, err := os.Stat(path); err == nil {
+		log.Printf("warning: ignored duplicate of '%s' in source\n", filepath.Base(path))
+		return nil
+	}
+	return write(path)
+}
+
+// CacheFilename returns the filename of a cached event or to-do, which is its UID (and the recurrence ID of an override),
+// so that its path stays the same across updates.
+// A UID that cannot be used as a filename is hashed.
+func CacheFilename(uid string, recurrenceId time.Time) string {
+	name := uid
+	if !recurrenceId.IsZero() {
+		name += "@" + recurrenceId.In(time.UTC).Format("20060102T150405Z")
+	}
+	if _, err := NewEventPath(CacheCalendar, name); err != nil || strings.HasPrefix(name, ".") || strings.TrimSpace(name) != name || len(name) > 200 {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+	}
+	return name
+}
+
+// CacheEvents collectively caches a list of events and to-dos under a certain directory, replacing its earlier contents.
+func (instance *Instance) CacheEvents(name string, eventsProps []EventProperties, todosProps []TodoProperties) error {
+	return instance.replaceCache(name, func(subDir string) error {
+		return instance.cacheEventsIn(subDir, eventsProps, todosProps)
+	})
+}
+
+func (instance *Instance) cacheEventsIn(subDir string, eventsProps []EventProperties, todosProps []TodoProperties) error {
+	// The directory exists even without events.
+	if err := CreateDir(filepath.Join(instance.getCacheDir(), subDir)); err != nil {
+		return err
+	}
+
+	for i, props := range eventsProps {
+		if err := instance.CacheEvent(subDir, i, props); err != nil {
+			return err
+		}
+	}
+
+	for i, props := range todosProps {
+		if err := instance.CacheTodo(subDir, i, props); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// replaceCache replaces the cache directory of a source with a new one, that write fills.
+// The new cache is written to a temporary directory, and only replaces the old cache once write has succeeded.
+// write is given the name of the temporary directory, relative to the cache directory.
+func (instance *Instance) replaceCache(name string, write func(subDir string) error) error {
+	cacheDir := instance.getCacheDir()
+	if err := CreateDir(cacheDir); err != nil {
+		return err
+	}
+
+	// The temporary directories are dotted, so that they are never read as sources.
+	newDir, err := os.MkdirTemp(cacheDir, "."+name+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(newDir)
+	if err := os.Chmod(newDir, 0755); err != nil {
+		return err
+	}
+
+	if err := write(filepath.Base(newDir)); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(cacheDir, name)
+	oldDir := newDir + "-old"
+	if err := os.Rename(dir, oldDir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(newDir, dir); err != nil {
+		// Put the old cache back.
+		os.Rename(oldDir, dir)
+		return err
+	}
+	return os.RemoveAll(oldDir)
+}
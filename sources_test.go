@@ -0,0 +1,353 @@
+package ian
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateIcalSourceConditionally(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T090000Z\r\nDTEND:20240506T100000Z\r\nSUMMARY:%s\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	summary := "lecture"
+	etag := `"1"`
+	var notModified int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+		w.Write([]byte(fmt.Sprintf(feed, summary)))
+	}))
+	defer server.Close()
+
+	instance := &Instance{Root: t.TempDir()}
+	instance.Config.Sources = map[string]CalendarSource{
+		// Expire on every update.
+		"school": {Source: server.URL, Type: "ical", Lifetime_: time.Nanosecond},
+	}
+
+	readSummary := func() string {
+		events, err := instance.ReadCachedEvents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("expected 1 cached event, got %d", len(events))
+		}
+		return events[0].Props.Summary
+	}
+
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s := readSummary(); s != "lecture" {
+		t.Errorf("expected 'lecture', got '%s'", s)
+	}
+
+	// A file that would be removed if the cache was written again.
+	marker := filepath.Join(instance.getCacheDir(), "school", ".marker")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if notModified != 1 {
+		t.Errorf("expected a conditional request, got %d", notModified)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("the cache was written although the source was not modified")
+	}
+
+	summary = "exam"
+	etag = `"2"`
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s := readSummary(); s != "exam" {
+		t.Errorf("expected the modified source to be cached, got '%s'", s)
+	}
+}
+
+func TestUpdateSourcesWhenUnavailable(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T090000Z\r\nDTEND:20240506T100000Z\r\nSUMMARY:lecture\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feed))
+	}))
+
+	instance := &Instance{Root: t.TempDir()}
+	instance.Config.Sources = map[string]CalendarSource{
+		"school": {Source: server.URL, Type: "ical", Lifetime_: time.Nanosecond},
+	}
+
+	expectCache := func() {
+		events, err := instance.ReadCachedEvents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].Props.Summary != "lecture" {
+			t.Errorf("expected the cache to be kept, got %v", events)
+		}
+	}
+
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectCache()
+
+	// A broken calendar cannot be parsed.
+	feed = "BEGIN:VCALENDAR\r\nBROKEN"
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectCache()
+
+	// Offline
+	server.Close()
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectCache()
+
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if journal.Sources["school"].Error == "" {
+		t.Error("expected the error to be recorded in the journal")
+	}
+}
+
+func TestUpdateSourcesWithTimeout(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T090000Z\r\nDTEND:20240506T100000Z\r\nSUMMARY:lecture\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+	hanging := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hanging" {
+			select {
+			case <-hanging:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+	defer close(hanging)
+
+	instance := &Instance{Root: t.TempDir()}
+	instance.Config.Sources = map[string]CalendarSource{
+		"school": {Source: server.URL, Type: "ical"},
+		"stuck":  {Source: server.URL + "/hanging", Type: "ical", Timeout_: 50 * time.Millisecond},
+	}
+
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := instance.ReadCachedEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("expected the other source to be updated, got %d events", len(events))
+	}
+
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if journal.Sources["stuck"].Error == "" {
+		t.Error("expected the timeout to be recorded in the journal")
+	}
+	if journal.Sources["school"].Error != "" {
+		t.Errorf("unexpected error for the other source: %s", journal.Sources["school"].Error)
+	}
+
+	// A canceled update is not recorded.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	instance.Config.Sources["stuck"] = CalendarSource{Source: server.URL + "/hanging", Type: "ical", Lifetime_: time.Nanosecond}
+	if err := instance.UpdateSources(ctx); err != context.Canceled {
+		t.Errorf("expected the cancellation, got %v", err)
+	}
+	if journal, err = instance.ReadCacheJournal(); err != nil {
+		t.Fatal(err)
+	}
+	if journal.Sources["stuck"].Error == "" {
+		t.Error("expected the earlier error to be kept")
+	}
+}
+
+func TestUpdateSourcesByName(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T090000Z\r\nDTEND:20240506T100000Z\r\nSUMMARY:%s\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	summary := "lecture"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hanging" {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(fmt.Sprintf(feed, summary)))
+	}))
+	defer server.Close()
+
+	instance := &Instance{Root: t.TempDir()}
+	instance.Config.Sources = map[string]CalendarSource{
+		"school": {Source: server.URL, Type: "ical"},
+		"stuck":  {Source: server.URL + "/hanging", Type: "ical", Timeout_: 50 * time.Millisecond},
+	}
+
+	if err := instance.UpdateSourcesByName(context.Background(), []string{"school"}); err != nil {
+		t.Fatal(err)
+	}
+	journal, err := instance.ReadCacheJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastUpdate := journal.Sources["school"].LastUpdate
+
+	// Within the lifetime.
+	summary = "exam"
+	if err := instance.UpdateSourcesByName(context.Background(), []string{"school", "stuck"}); err != nil {
+		t.Fatal(err)
+	}
+	events, err := instance.ReadCachedEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Props.Summary != "exam" {
+		t.Errorf("expected the source to be updated, got %v", events)
+	}
+	if journal, err = instance.ReadCacheJournal(); err != nil {
+		t.Fatal(err)
+	}
+	if !journal.Sources["school"].LastUpdate.After(lastUpdate) {
+		t.Error("expected the update to be recorded in the journal")
+	}
+	if journal.Sources["stuck"].Error == "" {
+		t.Error("expected the timeout to be recorded in the journal")
+	}
+
+	if err := instance.UpdateSourcesByName(context.Background(), []string{"nonexistent"}); err == nil {
+		t.Error("expected an error for an unknown source")
+	}
+}
+
+func TestStableCacheFilenames(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\n" +
+		"BEGIN:VEVENT\r\nUID:standup@example.com\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T090000Z\r\nDTEND:20240506T091500Z\r\nRRULE:FREQ=DAILY;COUNT=5\r\nSUMMARY:standup\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:standup@example.com\r\nRECURRENCE-ID:20240507T090000Z\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240507T100000Z\r\nDTEND:20240507T101500Z\r\nSUMMARY:standup\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:a/b\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240508T090000Z\r\nDTEND:20240508T100000Z\r\nSUMMARY:standup\r\nEND:VEVENT\r\n" +
+		// Two events without a UID, that are not duplicates.
+		strings.Repeat("BEGIN:VEVENT\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240509T090000Z\r\nDTEND:20240509T100000Z\r\nSUMMARY:retro\r\nEND:VEVENT\r\n", 2) +
+		"END:VCALENDAR\r\n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	instance := &Instance{Root: t.TempDir()}
+	instance.Config.Sources = map[string]CalendarSource{
+		"team": {Source: server.URL, Type: "ical", Lifetime_: time.Nanosecond},
+	}
+
+	readPaths := func() map[string]bool {
+		events, err := instance.ReadCachedEvents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths := map[string]bool{}
+		for _, event := range events {
+			paths[event.Path.String()] = true
+		}
+		return paths
+	}
+
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	paths := readPaths()
+	for _, path := range []string{".team/standup@example.com", ".team/standup@example.com@20240507T090000Z", ".team/" + CacheFilename("a/b", time.Time{})} {
+		if !paths[path] {
+			t.Errorf("expected '%s', got %v", path, paths)
+		}
+	}
+	if len(paths) != 5 {
+		t.Errorf("expected the events without a UID to be cached separately, got %v", paths)
+	}
+
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if again := readPaths(); fmt.Sprint(again) != fmt.Sprint(paths) {
+		t.Errorf("the paths changed from %v to %v", paths, again)
+	}
+
+	events, err := instance.ReadCachedEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := FindEvent(&events, "standup@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Props.IsOverride() {
+		t.Error("expected the UID to refer to the recurring event, not its override")
+	}
+	if event, err := FindEvent(&events, ".team/standup@example.com@20240507T090000Z"); err != nil || !event.Props.IsOverride() {
+		t.Errorf("expected the override by its path, got %v", err)
+	}
+}
+
+func TestSourceCalendarInfo(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//ian//test\r\nCOLOR:tomato\r\nX-PUBLISHED-TTL:PT1S\r\n" +
+		"BEGIN:VEVENT\r\nUID:1\r\nDTSTAMP:20240506T090000Z\r\nDTSTART:20240506T090000Z\r\nDTEND:20240506T100000Z\r\nSUMMARY:lecture\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	instance := &Instance{Root: t.TempDir()}
+	instance.Config.Sources = map[string]CalendarSource{
+		"school": {Source: server.URL, Type: "ical"},
+	}
+
+	if err := instance.Work(); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := instance.Config.GetContainerConfig(".school")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Color != (color.RGBA{255, 99, 71, 255}) {
+		t.Errorf("expected the color of the source, got %v", conf.Color)
+	}
+
+	// The source suggests to be updated every second, instead of every DefaultCacheLifetime.
+	time.Sleep(1100 * time.Millisecond)
+	if err := instance.UpdateSources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("expected the refresh interval of the source to be used, got %d requests", requests)
+	}
+}
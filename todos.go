@@ -0,0 +1,184 @@
+package ian
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/viper"
+)
+
+// ComponentTodo is written to the 'Component' key of to-do files, to tell them apart from event files.
+// Files without a component are events.
+const ComponentTodo string = "VTODO"
+
+type TodoStatus string
+
+const (
+	TodoStatusNeedsAction TodoStatus = "NEEDS-ACTION"
+	TodoStatusCompleted   TodoStatus = "COMPLETED"
+	TodoStatusInProcess   TodoStatus = "IN-PROCESS"
+	TodoStatusCancelled   TodoStatus = "CANCELLED"
+)
+
+type Todo struct {
+	Path EventPath
+
+	Props TodoProperties
+	// Constant is true if the to-do should not be changed. Used for source to-dos (cache).
+	Constant bool
+}
+
+// Write writes the to-do to the appropriate location in 'instance'.
+func (todo *Todo) Write(instance *Instance) error {
+	return todo.Props.Write(todo.Path.Filepath(instance))
+}
+
+func (todo *Todo) String() string {
+	return todo.Path.String()
+}
+
+type TodoProperties struct {
+	// Component is always ComponentTodo.
+	Component string
+
+	Uid string
+
+	Summary     string
+	Description string
+	Location    string
+	Url         string
+
+	// Start is an optional datetime representing when work on the to-do begins.
+	Start time.Time
+	// Due is an optional datetime representing when the to-do is expected to be completed.
+	Due time.Time
+	// Completed is the datetime when the to-do was actually completed.
+	Completed time.Time
+
+	// PercentComplete is the progress of the to-do, from 0 to 100.
+	PercentComplete int
+	// Priority is 0 for undefined, and otherwise 1 (highest) to 9 (lowest).
+	Priority int
+	Status   TodoStatus
+
+	// Extra are the iCalendar properties of the to-do that are not modelled by ian.
+	Extra []ExtraProperty
+
+	Created  time.Time
+	Modified time.Time
+}
+
+func (props *TodoProperties) Write(file string) error {
+	props.Component = ComponentTodo
+
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(props); err != nil {
+		return err
+	}
+
+	CreateDir(filepath.Dir(file)) // Create parent folder(s) leading to path.
+
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (props *TodoProperties) IsDone() bool {
+	return props.Status == TodoStatusCompleted || props.Status == TodoStatusCancelled
+}
+
+// Complete marks the to-do as completed at t.
+func (props *TodoProperties) Complete(t time.Time) {
+	props.Status = TodoStatusCompleted
+	props.Completed = t
+	props.PercentComplete = 100
+	props.Modified = t
+}
+
+// CompareTodos orders to-dos by their due date, and then by their priority. To-dos without a due date or a priority
+// come last.
+func CompareTodos(t1, t2 Todo) int {
+	switch {
+	case t1.Props.Due.IsZero() && !t2.Props.Due.IsZero():
+		return 1
+	case !t1.Props.Due.IsZero() && t2.Props.Due.IsZero():
+		return -1
+	case !t1.Props.Due.Equal(t2.Props.Due):
+		return t1.Props.Due.Compare(t2.Props.Due)
+	default:
+		return sortedPriority(t1.Props.Priority) - sortedPriority(t2.Props.Priority)
+	}
+}
+
+// sortedPriority returns the priority with the undefined priority (0) after the lowest (9).
+func sortedPriority(priority int) int {
+	if priority == 0 {
+		return 10
+	}
+	return priority
+}
+
+func (p *TodoProperties) Validate() error {
+	if viper.GetBool("no-validation") {
+		return nil
+	}
+	switch {
+	case p.Uid == "":
+		return errors.New("uid cannot be empty")
+	case p.Summary == "":
+		return errors.New("summary cannot be empty")
+	case !p.Start.IsZero() && !p.Due.IsZero() && p.Start.After(p.Due):
+		return errors.New("start cannot be chronologically after due")
+	case p.PercentComplete < 0 || p.PercentComplete > 100:
+		return errors.New("percent complete must be within 0-100")
+	case p.Priority < 0 || p.Priority > 9:
+		return errors.New("priority must be within 0-9")
+	case p.Created.After(p.Modified):
+		return errors.New("created cannot be chronologically after modified")
+	}
+
+	switch p.Status {
+	case "", TodoStatusNeedsAction, TodoStatusCompleted, TodoStatusInProcess, TodoStatusCancelled:
+	default:
+		return fmt.Errorf("invalid status '%s'", p.Status)
+	}
+
+	return nil
+}
+
+func (props *TodoProperties) FormatName() string {
+	return strings.NewReplacer(
+		"/", "-",
+		`\`, "-",
+		".", "_",
+	).Replace(props.Summary)
+}
+
+func GetTodo(todos *[]Todo, path string) (*Todo, error) {
+	for _, todo := range *todos {
+		if todo.Path.String() == path {
+			return &todo, nil
+		}
+	}
+	return nil, fmt.Errorf("no such to-do '%s'", path)
+}
+
+func FilterTodos(todos *[]Todo, filter func(*Todo) bool) []Todo {
+	filtered := []Todo{}
+
+	for _, todo := range *todos {
+		if filter(&todo) {
+			filtered = append(filtered, todo)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,31 @@
+package ian
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCompareTodos(t *testing.T) {
+	due := time.Date(2024, 5, 6, 17, 0, 0, 0, time.UTC)
+	todo := func(summary string, due time.Time, priority int) Todo {
+		return Todo{Props: TodoProperties{Summary: summary, Due: due, Priority: priority}}
+	}
+
+	todos := []Todo{
+		todo("someday", time.Time{}, 1),
+		todo("undefined", due, 0),
+		todo("low", due, 9),
+		todo("later", due.Add(time.Hour), 1),
+		todo("urgent", due, 1),
+	}
+	slices.SortFunc(todos, CompareTodos)
+
+	summaries := []string{}
+	for _, todo := range todos {
+		summaries = append(summaries, todo.Props.Summary)
+	}
+	if expected := []string{"urgent", "low", "undefined", "later", "someday"}; !slices.Equal(summaries, expected) {
+		t.Errorf("expected %v, got %v", expected, summaries)
+	}
+}